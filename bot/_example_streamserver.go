@@ -7,7 +7,7 @@ import (
 )
 
 func main() {
-	sc := NewStreamClient("127.0.0.1", 5000, true, "your_secret_token")
+	sc := NewStreamClient("127.0.0.1", 5000, true, "your_secret_token", nil, nil)
 
 	sc.OnConnected(func(message string) {
 		fmt.Println("Connected to server.")
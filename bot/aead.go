@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// CipherMode is the encryption mode of the TCP and UDP channels.
+type CipherMode string
+
+const (
+	// Original scheme: AES-CFB with one IV for the whole session, no integrity.
+	// Only used if the server does not offer anything else.
+	CipherLegacyCFB        CipherMode = "aes-256-cfb"
+	CipherAESGCM           CipherMode = "aes-256-gcm"
+	CipherChaCha20Poly1305 CipherMode = "chacha20-poly1305"
+	// TCP runs over TLS, UDP uses AES-GCM with a key exported from the TLS
+	// session.
+	CipherTLS CipherMode = "tls"
+)
+
+// Modes in order of preference
+var supportedCiphers = []CipherMode{CipherAESGCM, CipherChaCha20Poly1305}
+
+// Version of the key exchange message sent for AEAD modes. The legacy message
+// is iv (16 bytes) + key (32 bytes), the new one is version + cipher id + key.
+const keyExchangeVersion = 2
+
+// Servers which support AEAD append this line to their public key PEM. The
+// line is sent in plaintext, so the client uses it as the RSA-OAEP label of
+// its key message. A server which offered ciphers decrypts with its own line
+// as label, which fails if the line was changed or stripped on the way, e.g.
+// to force the legacy mode.
+const cipherOfferPrefix = "CIPHERS "
+
+// Nonce prefixes, one per direction and channel, so the same key can never
+// produce the same nonce twice.
+const (
+	nonceTCPClientToServer uint32 = 1
+	nonceTCPServerToClient uint32 = 2
+	nonceUDPClientToServer uint32 = 3
+)
+
+// Upper bound for one framed TCP message
+const maxFrameSize = 1 << 20
+
+func (m CipherMode) id() byte {
+	switch m {
+	case CipherAESGCM:
+		return 1
+	case CipherChaCha20Poly1305:
+		return 2
+	}
+	return 0
+}
+
+// parseCipherOffer reads the cipher list a server appends after its public
+// key, e.g. "CIPHERS aes-256-gcm,chacha20-poly1305", and returns it with the
+// OAEP label of the key message. Legacy servers send nothing, only get
+// CipherLegacyCFB and an empty label.
+func parseCipherOffer(rest []byte) ([]CipherMode, []byte) {
+	offered := make([]CipherMode, 0)
+	var label []byte
+	for _, line := range strings.Split(string(rest), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, cipherOfferPrefix) {
+			continue
+		}
+		label = []byte(line)
+		for _, name := range strings.Split(strings.TrimPrefix(line, cipherOfferPrefix), ",") {
+			offered = append(offered, CipherMode(strings.TrimSpace(name)))
+		}
+	}
+	return offered, label
+}
+
+// selectCipher picks the first of our supported modes the server offered.
+func selectCipher(offered []CipherMode) CipherMode {
+	for _, mode := range supportedCiphers {
+		for _, o := range offered {
+			if o == mode {
+				return mode
+			}
+		}
+	}
+	return CipherLegacyCFB
+}
+
+// sessionCipher seals and opens messages of one channel with an AEAD. Every
+// message carries its nonce: a 4 byte direction prefix and an 8 byte counter.
+// Received counters must strictly increase, which rejects replayed and
+// reordered messages.
+type sessionCipher struct {
+	lock        sync.Mutex
+	aead        cipher.AEAD
+	sendPrefix  uint32
+	recvPrefix  uint32
+	sendCounter uint64
+	recvCounter uint64
+}
+
+func newSessionCipher(mode CipherMode, key []byte, sendPrefix, recvPrefix uint32) (*sessionCipher, error) {
+	var aead cipher.AEAD
+	switch mode {
+	case CipherAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	case CipherChaCha20Poly1305:
+		var err error
+		aead, err = chacha20poly1305.New(key)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported cipher mode: %s", mode)
+	}
+
+	return &sessionCipher{
+		aead:       aead,
+		sendPrefix: sendPrefix,
+		recvPrefix: recvPrefix,
+	}, nil
+}
+
+// Seal encrypts the plaintext and returns nonce + ciphertext + tag.
+func (s *sessionCipher) Seal(plaintext []byte) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sendCounter++
+	nonce := make([]byte, s.aead.NonceSize())
+	binary.BigEndian.PutUint32(nonce[0:4], s.sendPrefix)
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], s.sendCounter)
+
+	return s.aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+// Open checks and decrypts a message created by the peer's Seal.
+func (s *sessionCipher) Open(message []byte) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	nonceSize := s.aead.NonceSize()
+	if len(message) < nonceSize+s.aead.Overhead() {
+		return nil, fmt.Errorf("message too short")
+	}
+	nonce := message[:nonceSize]
+	if binary.BigEndian.Uint32(nonce[0:4]) != s.recvPrefix {
+		return nil, fmt.Errorf("unexpected nonce prefix")
+	}
+	counter := binary.BigEndian.Uint64(nonce[nonceSize-8:])
+	if counter <= s.recvCounter {
+		return nil, fmt.Errorf("replayed message (counter %d)", counter)
+	}
+
+	plaintext, err := s.aead.Open(nil, nonce, message[nonceSize:], nil)
+	if err != nil {
+		return nil, err
+	}
+	s.recvCounter = counter
+	return plaintext, nil
+}
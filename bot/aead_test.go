@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestSessionCipher(t *testing.T) {
+	for _, mode := range supportedCiphers {
+		t.Run(string(mode), func(t *testing.T) {
+			key := make([]byte, 32)
+			rand.Read(key)
+			client, err := newSessionCipher(mode, key, nonceTCPClientToServer, nonceTCPServerToClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server, err := newSessionCipher(mode, key, nonceTCPServerToClient, nonceTCPClientToServer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first := client.Seal([]byte("first"))
+			second := client.Seal([]byte("second"))
+			for _, tt := range []struct {
+				message []byte
+				want    string
+			}{{first, "first"}, {second, "second"}} {
+				plaintext, err := server.Open(tt.message)
+				if err != nil {
+					t.Fatalf("open %q: %v", tt.want, err)
+				}
+				if string(plaintext) != tt.want {
+					t.Fatalf("got %q, want %q", plaintext, tt.want)
+				}
+			}
+
+			if _, err := server.Open(second); err == nil {
+				t.Error("replayed message was accepted")
+			}
+
+			tampered := client.Seal([]byte("third"))
+			tampered[len(tampered)-1] ^= 1
+			if _, err := server.Open(tampered); err == nil {
+				t.Error("tampered message was accepted")
+			}
+
+			// A message of the server must not be accepted as one of the client
+			if _, err := server.Open(server.Seal([]byte("reflected"))); err == nil {
+				t.Error("message with the wrong direction was accepted")
+			}
+
+			if _, err := server.Open([]byte{1, 2, 3}); err == nil {
+				t.Error("short message was accepted")
+			}
+		})
+	}
+}
+
+func TestParseCipherOffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		rest      string
+		wantMode  CipherMode
+		wantLabel string
+	}{
+		{"legacy server", "", CipherLegacyCFB, ""},
+		{"full offer", "CIPHERS aes-256-gcm,chacha20-poly1305\n", CipherAESGCM, "CIPHERS aes-256-gcm,chacha20-poly1305"},
+		{"preference of the client", "CIPHERS chacha20-poly1305, aes-256-gcm\n", CipherAESGCM, "CIPHERS chacha20-poly1305, aes-256-gcm"},
+		{"chacha only", "\nCIPHERS chacha20-poly1305", CipherChaCha20Poly1305, "CIPHERS chacha20-poly1305"},
+		{"unknown only", "CIPHERS rot13\n", CipherLegacyCFB, "CIPHERS rot13"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offered, label := parseCipherOffer([]byte(tt.rest))
+			if mode := selectCipher(offered); mode != tt.wantMode {
+				t.Errorf("mode %s, want %s", mode, tt.wantMode)
+			}
+			if string(label) != tt.wantLabel {
+				t.Errorf("label %q, want %q", label, tt.wantLabel)
+			}
+		})
+	}
+}
+
+// fakeKeyServer sends its public key followed by sent and returns the
+// decrypted key message, using offer as OAEP label like a real server which
+// offered ciphers.
+func fakeKeyServer(t *testing.T, conn net.Conn, key *rsa.PrivateKey, sent string, offer string) ([]byte, error) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if _, err := conn.Write(append(message, sent...)); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := make([]byte, key.Size())
+	if _, err := io.ReadFull(conn, encrypted); err != nil {
+		t.Fatal(err)
+	}
+	var label []byte
+	if offer != "" {
+		label = []byte(offer)
+	}
+	return rsa.DecryptOAEP(sha256.New(), nil, key, encrypted, label)
+}
+
+func TestKeyExchange(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const offer = "CIPHERS aes-256-gcm,chacha20-poly1305"
+
+	t.Run("AEAD", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+		defer serverConn.Close()
+
+		c := NewTCPclient("test", true)
+		c.connection = clientConn
+		errs := make(chan error, 1)
+		go func() { errs <- c.exchangeKeys() }()
+
+		keyMessage, err := fakeKeyServer(t, serverConn, key, offer+"\n", offer)
+		if err != nil {
+			t.Fatalf("server could not decrypt the key message: %v", err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if keyMessage[0] != keyExchangeVersion || keyMessage[1] != CipherAESGCM.id() {
+			t.Fatalf("unexpected key message header %v", keyMessage[:2])
+		}
+
+		// Frames in both directions
+		server, err := newSessionCipher(CipherAESGCM, keyMessage[2:], nonceTCPServerToClient, nonceTCPClientToServer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() { errs <- c.Send("token") }()
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(serverConn, header); err != nil {
+			t.Fatal(err)
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(serverConn, frame); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if plaintext, err := server.Open(frame); err != nil || string(plaintext) != "token" {
+			t.Fatalf("server got %q, %v", plaintext, err)
+		}
+
+		sealed := server.Seal([]byte("OK"))
+		reply := binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))
+		go serverConn.Write(append(reply, sealed...))
+		if message, err := c.readMessage(); err != nil || !bytes.Equal(message, []byte("OK")) {
+			t.Fatalf("client got %q, %v", message, err)
+		}
+	})
+
+	t.Run("stripped offer is detected", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+		defer serverConn.Close()
+
+		c := NewTCPclient("test", true)
+		c.connection = clientConn
+		errs := make(chan error, 1)
+		go func() { errs <- c.exchangeKeys() }()
+
+		// An attacker removed the offer, the client falls back to legacy
+		_, err := fakeKeyServer(t, serverConn, key, "", offer)
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if c.cipherMode != CipherLegacyCFB {
+			t.Fatalf("mode %s, want %s", c.cipherMode, CipherLegacyCFB)
+		}
+		if err == nil {
+			t.Fatal("server accepted a key message for a stripped offer")
+		}
+	})
+
+	t.Run("legacy server", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+		defer serverConn.Close()
+
+		c := NewTCPclient("test", true)
+		c.connection = clientConn
+		errs := make(chan error, 1)
+		go func() { errs <- c.exchangeKeys() }()
+
+		keyMessage, err := fakeKeyServer(t, serverConn, key, "", "")
+		if err != nil {
+			t.Fatalf("legacy server could not decrypt the key message: %v", err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(keyMessage, append(c.aesIV, c.aesKey...)) {
+			t.Fatal("legacy key message is not iv + key")
+		}
+	})
+}
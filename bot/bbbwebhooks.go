@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How often the callback is registered again. BBB removes hooks whose
+// callbacks keep failing, registering an existing hook again does nothing.
+const bbbWebhookRegisterInterval = 5 * time.Minute
+
+// Callbacks whose timestamp is further away from now are rejected, so a
+// captured callback can't be replayed later. bbb-webhooks keeps the
+// timestamp of the first attempt for all retries, which end after about five
+// minutes.
+const bbbWebhookMaxAge = 10 * time.Minute
+
+// BBBWebhookConfig configures the receiver for BBB server webhooks.
+type BBBWebhookConfig struct {
+	// Public URL of POST /api/v1/bbb/webhook, as registered with BBB
+	CallbackURL string
+	// Meetings whose name matches this glob pattern get a bot when they are
+	// created. Empty disables auto join.
+	JoinPattern   string
+	JoinTask      string
+	JoinLanguages []string
+}
+
+// bbbWebhookEvent is one entry of the event array BBB posts.
+type bbbWebhookEvent struct {
+	Data struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Meeting struct {
+				InternalMeetingID string `json:"internal-meeting-id"`
+				ExternalMeetingID string `json:"external-meeting-id"`
+				Name              string `json:"name"`
+			} `json:"meeting"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// BBBWebhooks registers the bot service with the BBB webhooks module and
+// starts and stops bots when meetings are created and ended.
+type BBBWebhooks struct {
+	config BBBWebhookConfig
+	apiURL string
+	secret string
+	client *http.Client
+}
+
+func NewBBBWebhooks(config BBBWebhookConfig, apiURL string, secret string) *BBBWebhooks {
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return &BBBWebhooks{
+		config: config,
+		apiURL: apiURL,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Register creates the hook for our callback URL.
+func (w *BBBWebhooks) Register() error {
+	query := "callbackURL=" + url.QueryEscape(w.config.CallbackURL)
+	var checksum string
+	if conf.BBB.API.SHA == "SHA1" {
+		checksum = hashHex(sha1.New(), "hooks/create"+query+w.secret)
+	} else {
+		checksum = hashHex(sha256.New(), "hooks/create"+query+w.secret)
+	}
+
+	resp, err := w.client.Get(w.apiURL + "hooks/create?" + query + "&checksum=" + checksum)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		ReturnCode string `xml:"returncode"`
+		HookID     string `xml:"hookID"`
+		MessageKey string `xml:"messageKey"`
+		Message    string `xml:"message"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("could not parse response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.ReturnCode != "SUCCESS" {
+		return fmt.Errorf("%s: %s", result.MessageKey, result.Message)
+	}
+	return nil
+}
+
+// Run registers the hook now and every bbbWebhookRegisterInterval until stop
+// is closed.
+func (w *BBBWebhooks) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(bbbWebhookRegisterInterval)
+	defer ticker.Stop()
+	registered := false
+	for {
+		if err := w.Register(); err != nil {
+			log.Printf("[ERROR] Failed to register BBB webhook: %v", err)
+			registered = false
+		} else if !registered {
+			log.Printf("[INFO] Registered BBB webhook for %s", w.config.CallbackURL)
+			registered = true
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Verify checks the checksum BBB sends with every callback and that the
+// callback is recent.
+func (w *BBBWebhooks) Verify(checksum string, form url.Values, body []byte) error {
+	if err := w.verifyChecksum(checksum, form, body); err != nil {
+		return err
+	}
+	return checkBBBWebhookTimestamp(form.Get("timestamp"), time.Now())
+}
+
+// verifyChecksum checks the hex encoded SHA-1 or SHA-256 of callback URL +
+// JSON of the form fields + secret. Older versions of bbb-webhooks hash the
+// raw body instead, which is accepted too.
+func (w *BBBWebhooks) verifyChecksum(checksum string, form url.Values, body []byte) error {
+	var newHash func() hash.Hash
+	switch len(checksum) {
+	case 40:
+		newHash = sha1.New
+	case 64:
+		newHash = sha256.New
+	case 128:
+		newHash = sha512.New
+	default:
+		return fmt.Errorf("missing or malformed checksum")
+	}
+
+	for _, data := range []string{bbbWebhookData(form), string(body)} {
+		expected := hashHex(newHash(), w.config.CallbackURL+data+w.secret)
+		if hmac.Equal([]byte(expected), []byte(strings.ToLower(checksum))) {
+			return nil
+		}
+	}
+	return fmt.Errorf("checksum mismatch")
+}
+
+// checkBBBWebhookTimestamp returns an error if the timestamp of a callback, in
+// milliseconds since the epoch, is more than bbbWebhookMaxAge away from now.
+func checkBBBWebhookTimestamp(timestamp string, now time.Time) error {
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or malformed timestamp")
+	}
+	age := now.Sub(time.UnixMilli(ms))
+	if age > bbbWebhookMaxAge || age < -bbbWebhookMaxAge {
+		return fmt.Errorf("timestamp is %s away from now", age.Round(time.Second))
+	}
+	return nil
+}
+
+// bbbWebhookData rebuilds JSON.stringify({event, timestamp, domain}) of the
+// bbb-webhooks module.
+func bbbWebhookData(form url.Values) string {
+	var buf bytes.Buffer
+	buf.WriteString(`{"event":`)
+	writeJSONString(&buf, form.Get("event"))
+	buf.WriteString(`,"timestamp":`)
+	buf.WriteString(form.Get("timestamp"))
+	if form.Has("domain") {
+		buf.WriteString(`,"domain":`)
+		writeJSONString(&buf, form.Get("domain"))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// writeJSONString encodes s like JSON.stringify does: only quotes,
+// backslashes and control characters are escaped, HTML characters, U+2028
+// and U+2029 are written as they are.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// Handle reacts to the events of one callback.
+func (w *BBBWebhooks) Handle(form url.Values) error {
+	events := make([]bbbWebhookEvent, 0)
+	if err := json.Unmarshal([]byte(form.Get("event")), &events); err != nil {
+		return fmt.Errorf("could not parse events: %w", err)
+	}
+
+	for _, event := range events {
+		meeting := event.Data.Attributes.Meeting
+		switch event.Data.ID {
+		case "meeting-created":
+			log.Printf("[INFO] BBB webhook: meeting %s (%s) created", meeting.ExternalMeetingID, meeting.Name)
+			go w.onMeetingCreated(meeting.ExternalMeetingID, meeting.Name)
+		case "meeting-ended":
+			log.Printf("[INFO] BBB webhook: meeting %s ended", meeting.ExternalMeetingID)
+			for _, bot := range BM.botList() {
+				if bot.MeetingID == meeting.ExternalMeetingID {
+					log.Printf("[INFO] Removing bot %s of ended meeting %s", bot.ID, meeting.ExternalMeetingID)
+					BM.RemoveBot(bot.ID, LeaveMeetingEnded)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (w *BBBWebhooks) onMeetingCreated(meetingID string, name string) {
+	// Schedules waiting for this meeting can join right away
+	scheduler.Trigger()
+
+	if w.config.JoinPattern == "" {
+		return
+	}
+	if ok, _ := path.Match(w.config.JoinPattern, name); !ok {
+		return
+	}
+	bot, err := joinMeeting(meetingID, w.config.JoinTask, w.config.JoinLanguages)
+	if err != nil {
+		log.Printf("[ERROR] Failed to join new meeting %s: %v", meetingID, err)
+		return
+	}
+	log.Printf("[INFO] Bot %s joined new meeting %s", bot.ID, meetingID)
+}
+
+func hashHex(h hash.Hash, data string) string {
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
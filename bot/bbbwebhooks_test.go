@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Callback as posted by bbb-webhooks: the form fields are
+// {event: "[" + message + "]", timestamp, domain} and the checksum is the hash
+// of callback URL + JSON.stringify(fields) + secret. The meeting name has
+// quotes, HTML characters, non-ASCII and U+2028 to catch differences to
+// JSON.stringify.
+const (
+	testBBBCallbackURL = "https://bot.example.com/api/v1/bbb/webhook"
+	testBBBSecret      = "8cd8ef52e8e101574e400365b55e11a6"
+	testBBBCallback    = "event=%5B%7B%22data%22%3A%7B%22type%22%3A%22event%22%2C%22id%22%3A%22meeting-created%22%2C%22attributes%22%3A%7B%22meeting%22%3A%7B%22internal-meeting-id%22%3A%22183f0bf3a0982a127bdb8161e0c44eb696b3e75c-1700000000000%22%2C%22external-meeting-id%22%3A%22team-sync%22%2C%22name%22%3A%22%3CTeam%20%26%20%5C%22Ops%5C%22%3E%20%E2%80%93%20Caf%C3%A9%2F%C3%9C%E2%80%A8%22%2C%22is-breakout%22%3Afalse%2C%22duration%22%3A0%2C%22create-time%22%3A1700000000000%2C%22metadata%22%3A%7B%7D%7D%7D%2C%22event%22%3A%7B%22ts%22%3A1700000000123%7D%7D%7D%5D&timestamp=1700000000123&domain=bbb.example.com"
+)
+
+func TestBBBWebhookVerifyChecksum(t *testing.T) {
+	w := NewBBBWebhooks(BBBWebhookConfig{CallbackURL: testBBBCallbackURL}, "https://bbb.example.com/bigbluebutton/api", testBBBSecret)
+
+	tests := []struct {
+		name     string
+		checksum string
+		body     string
+		wantErr  bool
+	}{
+		{"sha1", "57941782cd0be78d1cd9005192d557cc4de9ed02", testBBBCallback, false},
+		{"sha256", "a1b6c355f5c26a89ea06b05621042e6ef75db26c12742049c110e3ac08099ccb", testBBBCallback, false},
+		{"upper case", "57941782CD0BE78D1CD9005192D557CC4DE9ED02", testBBBCallback, false},
+		{"raw body", "a8d02a4ab12f0d55301b4cf4be9612c23fabfefb", testBBBCallback, false},
+		{"changed timestamp", "57941782cd0be78d1cd9005192d557cc4de9ed02", strings.Replace(testBBBCallback, "timestamp=1700000000123", "timestamp=1800000000123", 1), true},
+		{"changed meeting", "57941782cd0be78d1cd9005192d557cc4de9ed02", strings.Replace(testBBBCallback, "team-sync", "other", 1), true},
+		{"missing", "", testBBBCallback, true},
+		{"malformed", "57941782", testBBBCallback, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form, err := url.ParseQuery(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.verifyChecksum(tt.checksum, form, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	other := NewBBBWebhooks(BBBWebhookConfig{CallbackURL: testBBBCallbackURL}, "https://bbb.example.com/bigbluebutton/api", "wrong")
+	form, _ := url.ParseQuery(testBBBCallback)
+	if err := other.verifyChecksum("57941782cd0be78d1cd9005192d557cc4de9ed02", form, []byte(testBBBCallback)); err == nil {
+		t.Error("checksum with another secret was accepted")
+	}
+}
+
+func TestBBBWebhookVerifyRejectsReplay(t *testing.T) {
+	w := NewBBBWebhooks(BBBWebhookConfig{CallbackURL: testBBBCallbackURL}, "https://bbb.example.com/bigbluebutton/api", testBBBSecret)
+	form, err := url.ParseQuery(testBBBCallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The callback was signed in 2023
+	if err := w.Verify("57941782cd0be78d1cd9005192d557cc4de9ed02", form, []byte(testBBBCallback)); err == nil {
+		t.Error("old callback was accepted")
+	}
+}
+
+func TestCheckBBBWebhookTimestamp(t *testing.T) {
+	now := time.UnixMilli(1700000000123)
+	tests := []struct {
+		name      string
+		timestamp string
+		wantErr   bool
+	}{
+		{"now", "1700000000123", false},
+		{"retried", "1699999700123", false},
+		{"slightly ahead", "1700000060123", false},
+		{"too old", "1699999000000", true},
+		{"too far ahead", "1700001000000", true},
+		{"seconds", "1700000000", true},
+		{"missing", "", true},
+		{"not a number", "1e12", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBBBWebhookTimestamp(tt.timestamp, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBBBWebhookData(t *testing.T) {
+	form := url.Values{
+		"event":     {"[{\"name\":\"a\\\"b\"}]\n\t\x01</script> "},
+		"timestamp": {"1700000000123"},
+	}
+	want := `{"event":"[{\"name\":\"a\\\"b\"}]\n\t\u0001</script>` + " " + `","timestamp":1700000000123}`
+	if got := bbbWebhookData(form); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	bbbbot "github.com/bigbluebutton-bot/bigbluebutton-bot"
 	"github.com/bigbluebutton-bot/bigbluebutton-bot/pad"
@@ -29,13 +32,22 @@ type BotManager struct {
 	bbb_api_url            string
 	bbb_api_secret         string
 	bbb_webrtc_ws          string
-	transcription_host     string
-	transcription_port     int
+	transcription_pool     *TranscriptionPool
 	transcription_secret   string
+	transcription_tls      *tls.Config
+	transcription_keys     *KeyVerifier
 	translation_server_url string
 	changeset_external     bool
 	changeset_port         int
 	changeset_host         string
+	silence_gate           SilenceGateConfig
+	webhooks               *Webhooks
+	policy                 LifecyclePolicy
+	chat_commands          ChatCommandConfig
+	source_language        string
+	pad_budget             PadBudget
+	hls                    HLSConfig
+	caption_sinks          CaptionSinkConfig
 }
 
 func NewBotManager(
@@ -47,13 +59,22 @@ func NewBotManager(
 	bbb_api_url string,
 	bbb_api_secret string,
 	bbb_webrtc_ws string,
-	transcription_host string,
-	transcription_port int,
+	transcription_pool *TranscriptionPool,
 	transcription_secret string,
+	transcription_tls *tls.Config,
+	transcription_keys *KeyVerifier,
 	translation_server_url string,
 	changeset_external bool,
 	changeset_port int,
 	changeset_host string,
+	silence_gate SilenceGateConfig,
+	webhooks *Webhooks,
+	policy LifecyclePolicy,
+	chat_commands ChatCommandConfig,
+	source_language string,
+	pad_budget PadBudget,
+	hls HLSConfig,
+	caption_sinks CaptionSinkConfig,
 ) *BotManager {
 	return &BotManager{
 		Max_bots:               max_bots,
@@ -65,13 +86,22 @@ func NewBotManager(
 		bbb_api_url:            bbb_api_url,
 		bbb_api_secret:         bbb_api_secret,
 		bbb_webrtc_ws:          bbb_webrtc_ws,
-		transcription_host:     transcription_host,
-		transcription_port:     transcription_port,
+		transcription_pool:     transcription_pool,
 		transcription_secret:   transcription_secret,
+		transcription_tls:      transcription_tls,
+		transcription_keys:     transcription_keys,
 		translation_server_url: translation_server_url,
 		changeset_external:     changeset_external,
 		changeset_port:         changeset_port,
 		changeset_host:         changeset_host,
+		silence_gate:           silence_gate,
+		webhooks:               webhooks,
+		policy:                 policy,
+		chat_commands:          chat_commands,
+		source_language:        source_language,
+		pad_budget:             pad_budget,
+		hls:                    hls,
+		caption_sinks:          caption_sinks,
 	}
 }
 
@@ -81,8 +111,7 @@ func (bm *BotManager) AddBot() (*Bot, error) {
 		return nil, fmt.Errorf("max bots reached: %d", bm.Max_bots)
 	}
 
-	// transcription_host string,
-	// transcription_port int,
+	// transcription_pool *TranscriptionPool,
 	// transcription_secret string,
 	// translation_server_url string,
 	// changeset_external bool,
@@ -96,30 +125,44 @@ func (bm *BotManager) AddBot() (*Bot, error) {
 		bm.bbb_api_url,
 		bm.bbb_api_secret,
 		bm.bbb_webrtc_ws,
-		bm.transcription_host,
-		bm.transcription_port,
+		bm.transcription_pool,
 		bm.transcription_secret,
+		bm.transcription_tls,
+		bm.transcription_keys,
 		bm.translation_server_url,
 		bm.changeset_external,
 		bm.changeset_port,
 		bm.changeset_host,
+		bm.silence_gate,
+		bm.webhooks,
+		bm.policy,
+		bm.chat_commands,
+		bm.source_language,
+		bm.pad_budget,
+		bm.hls,
+		bm.caption_sinks,
 		TaskTranscribe,
 	)
 	bm.lock.Lock()
 	defer bm.lock.Unlock()
 
 	bm.bots[new_bot.ID] = new_bot
+	bm.webhooks.Emit(EventBotCreated, new_bot, nil)
 
 	return new_bot, nil
 }
 
-func (bm *BotManager) RemoveBot(botID string) {
+// RemoveBot disconnects the bot. The reason is sent with the bot.removed
+// webhook, the bot can't be fetched through the API anymore.
+func (bm *BotManager) RemoveBot(botID string, reason string) {
 	bm.lock.Lock()
 	defer bm.lock.Unlock()
 
 	if bot, ok := bm.bots[botID]; ok {
+		log.Printf("[INFO] Bot %s leaves meeting %s: %s", botID, bot.MeetingID, reason)
 		bot.Disconnect()
 		delete(bm.bots, botID)
+		bm.webhooks.Emit(EventBotRemoved, bot, map[string]any{"reason": reason})
 	}
 }
 
@@ -133,6 +176,18 @@ func (bm *BotManager) Bot(botID string) (*Bot, bool) {
 	return nil, false
 }
 
+// botList returns a snapshot of all bots, safe to use while bots are removed.
+func (bm *BotManager) botList() []*Bot {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	bots := make([]*Bot, 0, len(bm.bots))
+	for _, bot := range bm.bots {
+		bots = append(bots, bot)
+	}
+	return bots
+}
+
 func (bm *BotManager) Bots() map[string]*Bot {
 	bm.lock.Lock()
 	defer bm.lock.Unlock()
@@ -148,6 +203,13 @@ const (
 	TaskTranslate
 )
 
+func (t Task) String() string {
+	if t == TaskTranslate {
+		return "translate"
+	}
+	return "transcribe"
+}
+
 type StatusType int
 
 const (
@@ -165,10 +227,33 @@ type Bot struct {
 	Sub_bots     int                       `json:"sub_bots"`
 	Languages    []string                  `json:"languages"`
 	clientsMutex sync.Mutex
+	streamLock   sync.Mutex // guards streamclient, oggFile and server
+	policyLock   sync.Mutex // guards Policy, JoinedAt and aloneSince
 	streamclient *StreamClient
 	audioclient  *bbbbot.AudioClient
 	oggFile      *oggwriter.OggWriter
-	en_caption   *pad.Pad
+	jitterbuffer *JitterBuffer
+	silencegate  *SilenceGate
+	Jitter       JitterStats  `json:"jitter"`
+	Silence      SilenceStats `json:"silence"`
+	UDP          UDPStats     `json:"udp"`
+	Protocol     Capabilities `json:"protocol"`
+
+	// Capture of the spoken language
+	source_caption *pad.Pad
+	// Writers of all captures, guarded by clientsMutex
+	pad_writers map[*pad.Pad]*PadWriter
+	pad_budget  PadBudget
+	// Text trimmed from the pads
+	history *TranscriptHistory
+	// Captions as WebVTT subtitle playlists for HLS players
+	hls *HLSFeed
+	// Destinations added through the API, guarded by clientsMutex
+	sinks       map[string]*captionSinkEntry
+	sink_config CaptionSinkConfig
+
+	server              *TranscriptionServer
+	TranscriptionServer string `json:"transcription_server"`
 
 	bbb_client_url         string
 	bbb_client_ws          string
@@ -177,15 +262,29 @@ type Bot struct {
 	bbb_api_url            string
 	bbb_api_secret         string
 	bbb_webrtc_ws          string
-	transcription_host     string
-	transcription_port     int
+	transcription_pool     *TranscriptionPool
 	transcription_secret   string
+	transcription_tls      *tls.Config
+	transcription_keys     *KeyVerifier
 	translation_server_url string
 	changeset_external     bool
 	changeset_port         int
 	changeset_host         string
+	webhooks               *Webhooks
+	chat_commands          ChatCommandConfig
 	Task                   Task `json:"task"`
 
+	// Language spoken in the meeting, source of all translations
+	SourceLanguage string `json:"source_language"`
+	// Language the transcription server currently hears, if it detects it
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	// Last detected language which has no BBB code
+	unknownLanguage string
+
+	Policy     LifecyclePolicy `json:"policy"`
+	JoinedAt   time.Time       `json:"joined_at"`
+	aloneSince time.Time
+
 	MeetingID string `json:"meeting_id"`
 	UserName  string `json:"user_name"`
 	moderator bool
@@ -199,13 +298,22 @@ func NewBot(
 	bbb_api_url string,
 	bbb_api_secret string,
 	bbb_webrtc_ws string,
-	transcription_host string,
-	transcription_port int,
+	transcription_pool *TranscriptionPool,
 	transcription_secret string,
+	transcription_tls *tls.Config,
+	transcription_keys *KeyVerifier,
 	translation_server_url string,
 	changeset_external bool,
 	changeset_port int,
 	changeset_host string,
+	silence_gate SilenceGateConfig,
+	webhooks *Webhooks,
+	policy LifecyclePolicy,
+	chat_commands ChatCommandConfig,
+	source_language string,
+	pad_budget PadBudget,
+	hls HLSConfig,
+	sink_config CaptionSinkConfig,
 	task Task,
 ) *Bot {
 	client, err := bbbbot.NewClient(
@@ -221,8 +329,6 @@ func NewBot(
 		panic(err)
 	}
 
-	streamclient := NewStreamClient(transcription_host, transcription_port, true, transcription_secret)
-
 	// Create obj
 	return_bot := &Bot{
 		ID:           uuid.New().String(),
@@ -232,10 +338,11 @@ func NewBot(
 		clients:      make(map[string]*bbbbot.Client),
 		captures:     make(map[string]*pad.Pad),
 		Languages:    make([]string, 0),
-		streamclient: streamclient,
+		streamclient: nil,
 		audioclient:  client.CreateAudioChannel(),
 		oggFile:      nil,
-		en_caption:   nil,
+		jitterbuffer: NewJitterBuffer(defaultJitterDepth),
+		silencegate:  NewSilenceGate(silence_gate),
 
 		bbb_client_url:         bbb_client_url,
 		bbb_client_ws:          bbb_client_ws,
@@ -244,23 +351,35 @@ func NewBot(
 		bbb_api_url:            bbb_api_url,
 		bbb_api_secret:         bbb_api_secret,
 		bbb_webrtc_ws:          bbb_webrtc_ws,
+		transcription_pool:     transcription_pool,
+		transcription_secret:   transcription_secret,
+		transcription_tls:      transcription_tls,
+		transcription_keys:     transcription_keys,
 		translation_server_url: translation_server_url,
 		changeset_port:         changeset_port,
 		changeset_host:         changeset_host,
 		changeset_external:     changeset_external,
+		webhooks:               webhooks,
+		chat_commands:          chat_commands,
+		Policy:                 policy,
+		SourceLanguage:         source_language,
+		pad_budget:             pad_budget,
+		history:                NewTranscriptHistory(),
+		hls:                    NewHLSFeed(hls),
+		sink_config:            sink_config,
 
 		MeetingID: "",
 		UserName:  "",
 		moderator: true,
 	}
-	return_bot.Languages = append(return_bot.Languages, "en")
+	return_bot.Languages = append(return_bot.Languages, source_language)
 	return return_bot
 }
 
 func (b *Bot) Join(
 	meetingID string,
 	UserName string,
-) error {
+) (err error) {
 	if b.Status == Connecting {
 		// return error connecting
 		return fmt.Errorf("already connecting")
@@ -276,87 +395,44 @@ func (b *Bot) Join(
 
 	b.MeetingID = meetingID
 	b.UserName = UserName
+	b.policyLock.Lock()
+	b.JoinedAt = time.Now()
+	b.aloneSince = time.Time{}
+	b.policyLock.Unlock()
+	defer func() {
+		if err != nil {
+			b.webhooks.Emit(EventBotJoinFailed, b, map[string]any{"error": err.Error()})
+		} else {
+			b.webhooks.Emit(EventBotJoined, b, nil)
+		}
+	}()
 
-	err := b.client.Join(b.MeetingID, b.UserName, b.moderator)
+	err = b.client.Join(b.MeetingID, b.UserName, b.moderator)
 	if err != nil {
 		return err
 	}
 
-	b.en_caption, err = b.client.CreateCapture(bbbbot.Language("en"), b.changeset_external, b.changeset_host, b.changeset_port)
+	if b.chat_commands.Enabled {
+		if err := b.client.OnGroupChatMsg(b.onChatMessage); err != nil {
+			log.Println("Could not listen to chat commands:", err)
+		}
+	}
+
+	b.source_caption, err = b.createSourceCaption(b.SourceLanguage)
 	if err != nil {
 		return err
 	}
 
-	b.en_caption.OnDisconnect(func() {
-		log.Println("En caption disconnected")
-		b.Disconnect()
-	})
-
-	b.streamclient.OnConnected(func(message string) {
-		log.Println("Connected to server.")
-	})
-
-	b.streamclient.OnDisconnected(func(message string) {
-		log.Println("Disconnected from server.")
-		b.client.Leave()
-	})
-
-	b.streamclient.OnTimeout(func(message string) {
-		log.Println("Connection to server timed out.")
-		b.client.Leave()
-	})
-
-	b.streamclient.OnTCPMessage(func(text string) {
-		log.Println("TCP message event:", text)
-		validtext := strings.ToValidUTF8(text, "")
-
-		if b.Task == TaskTranscribe {
-			// use the english capture
-			captures := b.client.GetCaptures()
-			for _, capture := range captures {
-				if capture.ShortLanguageName == "en" {
-					err := capture.SetText(validtext)
-					if err != nil {
-						log.Println("Error in pad write:", err)
-					}
-				}
-			}
-		} else if b.Task == TaskTranslate {
-			// use the english capture to set the text
-			captures := b.client.GetCaptures()
-			for _, capture := range captures {
-				if capture.ShortLanguageName == "en" {
-					err := capture.SetText(validtext)
-					if err != nil {
-						log.Println("Error in pad write:", err)
-					}
-				}
-			}
-			// use the other captures to set the text
-			clients := b.clients
-			for _, client := range clients {
-				captures = client.GetCaptures()
-				for _, capture := range captures {
-					if capture.ShortLanguageName != "en" {
-						translatedText, err := translate(b.translation_server_url, validtext, "en", capture.ShortLanguageName)
-						if err != nil {
-							log.Println("Error in translation:", err)
-						}
-						err = capture.SetText(translatedText)
-						if err != nil {
-							log.Println("Error in pad write:", err)
-						}
-					}
-				}
-			}
-		}
-	})
-
-	err = b.streamclient.Connect()
+	err = b.connectStream()
 	if err != nil {
 		return err
 	}
 
+	// Tell the server which language to expect
+	if err := b.sendTaskRequest(b.Task.String()); err != nil {
+		log.Println("Error in task request send:", err)
+	}
+
 	b.audioclient = b.client.CreateAudioChannel()
 
 	err = b.audioclient.ListenToAudio()
@@ -364,10 +440,8 @@ func (b *Bot) Join(
 		panic(err)
 	}
 
-	b.oggFile, err = oggwriter.NewWith(b.streamclient, 48000, 2)
-	if err != nil {
-		panic(err)
-	}
+	b.jitterbuffer = NewJitterBuffer(defaultJitterDepth)
+	b.silencegate.Reset()
 
 	b.audioclient.OnTrack(func(status *bbbbot.StatusType, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
 		// Only handle audio tracks
@@ -387,8 +461,18 @@ func (b *Bot) Join(
 
 		go func() {
 			buffer := make([]byte, 1024)
-			defer b.oggFile.Close()
-			defer b.streamclient.Close()
+			defer b.closeStream()
+			defer func() {
+				// Write out whatever is still waiting in the jitter buffer
+				for _, packet := range b.jitterbuffer.Flush() {
+					if !b.silencegate.Allow(packet) {
+						continue
+					}
+					if err := b.writeAudio(packet); err != nil {
+						return
+					}
+				}
+			}()
 			for {
 				n, _, readErr := track.Read(buffer)
 
@@ -401,19 +485,30 @@ func (b *Bot) Join(
 					return
 				}
 
+				// The jitter buffer keeps packets around, so they must not
+				// share the read buffer
+				data := make([]byte, n)
+				copy(data, buffer[:n])
+
 				rtpPacket := &rtp.Packet{}
-				if err := rtpPacket.Unmarshal(buffer[:n]); err != nil {
+				if err := rtpPacket.Unmarshal(data); err != nil {
 					log.Println("Error during RTP packet unmarshal:", err)
 					return
 				}
 
-				if err := b.oggFile.WriteRTP(rtpPacket); err != nil {
-					if *status == bbbbot.DISCONNECTED {
-						return
+				for _, packet := range b.jitterbuffer.Push(rtpPacket) {
+					if !b.silencegate.Allow(packet) {
+						continue
 					}
+					if err := b.writeAudio(packet); err != nil {
+						if *status == bbbbot.DISCONNECTED {
+							return
+						}
 
-					log.Println("Error during OGG file write:", err)
-					return
+						// Keep reading, the stream may be moved to
+						// another transcription server
+						log.Println("Error during OGG file write:", err)
+					}
 				}
 			}
 		}()
@@ -422,19 +517,389 @@ func (b *Bot) Join(
 	return nil
 }
 
-func (b *Bot) Disconnect() {
-	if b.streamclient != nil {
-		b.streamclient.Close()
+// onTranscript writes a segment received from the transcription server to
+// the capture of the spoken language. In translate mode every other capture
+// gets a translation of the confirmed words, drafts are not translated.
+func (b *Bot) onTranscript(message string) {
+	log.Println("TCP message event:", message)
+	transcript, ok := ParseTranscript(message)
+	if !ok {
+		log.Printf("[WARN] Bot %s: ignoring message which is no transcript: %s", b.ID, message)
+		return
+	}
+	if transcript.Language != "" {
+		b.onLanguage(transcript.Language)
+	}
+	spoken := b.spokenLanguage()
+
+	// Segments in a language without a capture go to the source capture. In
+	// transcribe mode there are no captures besides the source.
+	b.clientsMutex.Lock()
+	target := b.SourceLanguage
+	if _, ok := b.captures[spoken]; ok && b.Task == TaskTranslate {
+		target = spoken
+	}
+	// Every captioned language goes to its pad, the HLS feed and the sinks
+	// added through the API
+	sinks := make(map[string][]CaptionSink, len(b.captures)+1)
+	if b.Task == TaskTranslate {
+		for lang, capture := range b.captures {
+			sinks[lang] = []CaptionSink{b.padWriter(capture)}
+		}
+	}
+	if b.source_caption != nil {
+		sinks[b.SourceLanguage] = []CaptionSink{b.padWriter(b.source_caption)}
+	}
+	for lang := range sinks {
+		sinks[lang] = append(sinks[lang], b.hls.Sink(lang))
+		for _, entry := range b.sinks {
+			if entry.info.Language == "" || entry.info.Language == lang {
+				sinks[lang] = append(sinks[lang], entry.sink)
+			}
+		}
+	}
+	b.clientsMutex.Unlock()
+
+	// The stream client hands over one transcript at a time, so the segments
+	// reach each sink in order. The languages are translated in parallel.
+	text := strings.ToValidUTF8(transcript.Text(), "")
+	confirmed := strings.ToValidUTF8(transcript.Confirmed, "")
+	var wg sync.WaitGroup
+	for lang, langSinks := range sinks {
+		if lang != target && (b.Task != TaskTranslate || confirmed == "") {
+			continue
+		}
+		wg.Add(1)
+		go func(lang string, langSinks []CaptionSink) {
+			defer wg.Done()
+			captionText := text
+			if lang != target {
+				translatedText, err := translate(b.translation_server_url, confirmed, spoken, lang)
+				if err != nil {
+					log.Println("Error in translation:", err)
+				}
+				captionText = translatedText
+			}
+			caption := Caption{
+				BotID:     b.ID,
+				MeetingID: b.MeetingID,
+				Language:  lang,
+				SegmentID: transcript.SegmentID,
+				Text:      captionText,
+				Final:     transcript.Final,
+				Time:      time.Now(),
+			}
+			for _, sink := range langSinks {
+				if err := sink.WriteCaption(caption); err != nil {
+					log.Println("Error in caption sink:", err)
+				}
+			}
+		}(lang, langSinks)
+	}
+	wg.Wait()
+}
+
+// History returns the caption text trimmed from the pads of lang, or of all
+// languages if lang is empty.
+func (b *Bot) History(lang string) map[string][]HistoryEntry {
+	return b.history.Entries(lang)
+}
+
+// AddSink sends the captions of the bot to another destination as well.
+func (b *Bot) AddSink(spec CaptionSinkSpec) (CaptionSinkInfo, error) {
+	if spec.Language != "" && !isValidLanguage(spec.Language) {
+		return CaptionSinkInfo{}, fmt.Errorf("invalid language: %s", spec.Language)
+	}
+	b.clientsMutex.Lock()
+	full := b.sinksFull()
+	b.clientsMutex.Unlock()
+	if full {
+		return CaptionSinkInfo{}, fmt.Errorf("max %d sinks per bot", b.sink_config.MaxPerBot)
+	}
+	if spec.Type == "webhook" && spec.Secret == "" {
+		secret, err := generateSinkSecret()
+		if err != nil {
+			return CaptionSinkInfo{}, err
+		}
+		spec.Secret = secret
+	}
+	sink, err := newCaptionSink(spec, b.sink_config)
+	if err != nil {
+		return CaptionSinkInfo{}, err
+	}
+	info := CaptionSinkInfo{
+		ID:              uuid.New().String(),
+		CaptionSinkSpec: spec,
+		CreatedAt:       time.Now(),
+	}
+
+	b.clientsMutex.Lock()
+	defer b.clientsMutex.Unlock()
+	// Another sink may have been added meanwhile
+	if b.sinksFull() {
+		sink.Close()
+		return CaptionSinkInfo{}, fmt.Errorf("max %d sinks per bot", b.sink_config.MaxPerBot)
+	}
+	if b.sinks == nil {
+		b.sinks = make(map[string]*captionSinkEntry)
+	}
+	b.sinks[info.ID] = &captionSinkEntry{info: info, sink: sink}
+	return info, nil
+}
+
+// sinksFull reports whether the bot has as many sinks as it may have.
+// clientsMutex must be held.
+func (b *Bot) sinksFull() bool {
+	return b.sink_config.MaxPerBot > 0 && len(b.sinks) >= b.sink_config.MaxPerBot
+}
+
+// RemoveSink stops sending captions to the sink.
+func (b *Bot) RemoveSink(id string) bool {
+	b.clientsMutex.Lock()
+	entry, ok := b.sinks[id]
+	delete(b.sinks, id)
+	b.clientsMutex.Unlock()
+
+	if !ok {
+		return false
+	}
+	if err := entry.sink.Close(); err != nil {
+		log.Printf("[WARN] Bot %s: failed to close sink %s: %v", b.ID, id, err)
+	}
+	return true
+}
+
+// Sinks returns the sinks added through the API without their secrets.
+func (b *Bot) Sinks() []CaptionSinkInfo {
+	b.clientsMutex.Lock()
+	defer b.clientsMutex.Unlock()
+
+	sinks := make([]CaptionSinkInfo, 0, len(b.sinks))
+	for _, entry := range b.sinks {
+		info := entry.info
+		info.Secret = ""
+		sinks = append(sinks, info)
+	}
+	sort.Slice(sinks, func(i, j int) bool {
+		return sinks[i].CreatedAt.Before(sinks[j].CreatedAt)
+	})
+	return sinks
+}
+
+// HLS returns the captions of the bot as WebVTT subtitle playlists.
+func (b *Bot) HLS() *HLSFeed {
+	return b.hls
+}
+
+// padWriter returns the writer of the capture. clientsMutex must be held.
+func (b *Bot) padWriter(capture *pad.Pad) *PadWriter {
+	if b.pad_writers == nil {
+		b.pad_writers = make(map[*pad.Pad]*PadWriter)
+	}
+	writer, ok := b.pad_writers[capture]
+	if !ok {
+		writer = NewPadWriter(capture, b.pad_budget, func(text string) {
+			b.history.Add(capture.ShortLanguageName, text)
+		})
+		b.pad_writers[capture] = writer
 	}
+	return writer
+}
+
+// spokenLanguage returns the detected language, or the source language if
+// the server does not detect it.
+func (b *Bot) spokenLanguage() string {
+	b.clientsMutex.Lock()
+	defer b.clientsMutex.Unlock()
+
+	if b.DetectedLanguage != "" {
+		return b.DetectedLanguage
+	}
+	return b.SourceLanguage
+}
+
+// onLanguage is called when the transcription server detects a different
+// spoken language. The server sends ISO 639-1 codes, which are mapped to BBB
+// codes. Unknown languages are ignored.
+func (b *Bot) onLanguage(detected string) {
+	lang, ok := ConvertISOToBBB(detected)
+	b.clientsMutex.Lock()
+	if !ok {
+		// Only logged once, every segment carries the language
+		if detected != b.unknownLanguage {
+			log.Printf("Bot %s: ignoring unknown detected language %q", b.ID, detected)
+			b.unknownLanguage = detected
+		}
+		b.clientsMutex.Unlock()
+		return
+	}
+	b.unknownLanguage = ""
+	previous := b.DetectedLanguage
+	b.DetectedLanguage = lang
+	b.clientsMutex.Unlock()
+	if lang == previous {
+		return
+	}
+	log.Printf("Bot %s: detected language changed from %q to %s", b.ID, previous, lang)
+	b.webhooks.Emit(EventBotLanguageChanged, b, map[string]any{"language": lang, "previous": previous})
+}
+
+// connectStream connects to the least loaded healthy transcription server and
+// points the Ogg writer at it. Servers in exclude are not used.
+func (b *Bot) connectStream(exclude ...*TranscriptionServer) error {
+	for {
+		server, err := b.transcription_pool.Acquire(exclude...)
+		if err != nil {
+			return err
+		}
+		log.Printf("Bot %s uses transcription server %s", b.ID, server.Address())
+
+		streamclient := NewStreamClient(server.Host, server.Port, true, b.transcription_secret, b.transcription_tls, b.transcription_keys)
+
+		streamclient.OnConnected(func(message string) {
+			log.Println("Connected to server.")
+		})
+
+		streamclient.OnDisconnected(func(message string) {
+			log.Println("Disconnected from server.")
+			b.onStreamLost(streamclient)
+		})
+
+		streamclient.OnTimeout(func(message string) {
+			log.Println("Connection to server timed out.")
+			b.onStreamLost(streamclient)
+		})
+
+		streamclient.OnTCPMessage(b.onTranscript)
+		streamclient.OnLanguage(b.onLanguage)
+
+		err = streamclient.Connect()
+		if err == nil {
+			var oggFile *oggwriter.OggWriter
+			oggFile, err = oggwriter.NewWith(streamclient, 48000, 2)
+			if err == nil {
+				b.streamLock.Lock()
+				b.streamclient = streamclient
+				b.oggFile = oggFile
+				b.server = server
+				b.streamLock.Unlock()
+				return nil
+			}
+			streamclient.Close()
+		}
+
+		log.Printf("Failed to connect to transcription server %s: %v", server.Address(), err)
+		b.transcription_pool.Release(server)
+		b.transcription_pool.MarkDown(server)
+		exclude = append(exclude, server)
+	}
+}
+
+// onStreamLost moves the bot to another transcription server after its
+// current one went away. If no server is left, the bot leaves the meeting.
+func (b *Bot) onStreamLost(streamclient *StreamClient) {
+	b.streamLock.Lock()
+	if b.streamclient != streamclient {
+		// Closed on purpose or already handled
+		b.streamLock.Unlock()
+		return
+	}
+	server := b.server
+	b.streamclient = nil
+	b.oggFile = nil
+	b.server = nil
+	b.streamLock.Unlock()
+
+	b.transcription_pool.Release(server)
+	b.transcription_pool.MarkDown(server)
+	log.Printf("Transcription server %s lost, moving bot %s to another server", server.Address(), b.ID)
+
+	if err := b.connectStream(server); err != nil {
+		log.Println("Failover failed:", err)
+		b.webhooks.Emit(EventBotStreamLost, b, map[string]any{
+			"server":   server.Address(),
+			"failover": false,
+			"error":    err.Error(),
+		})
+		b.client.Leave()
+		return
+	}
+	b.webhooks.Emit(EventBotStreamLost, b, map[string]any{
+		"server":   server.Address(),
+		"failover": true,
+	})
+
+	// The new server starts in transcribe mode and does not know the language
+	if err := b.sendTaskRequest(b.Task.String()); err != nil {
+		log.Println("Error in task request send:", err)
+	}
+}
+
+// writeAudio writes the packet to the current transcription stream. Packets
+// are dropped while the stream is being moved to another server.
+func (b *Bot) writeAudio(packet *rtp.Packet) error {
+	b.streamLock.Lock()
+	defer b.streamLock.Unlock()
+
+	if b.oggFile == nil {
+		return nil
+	}
+	return b.oggFile.WriteRTP(packet)
+}
+
+// stream returns the current stream client, or nil while not connected.
+func (b *Bot) stream() *StreamClient {
+	b.streamLock.Lock()
+	defer b.streamLock.Unlock()
+
+	return b.streamclient
+}
+
+// closeStream closes the stream to the transcription server and frees the
+// bot's slot on it.
+func (b *Bot) closeStream() {
+	b.streamLock.Lock()
+	streamclient, oggFile, server := b.streamclient, b.oggFile, b.server
+	b.streamclient = nil
+	b.oggFile = nil
+	b.server = nil
+	b.streamLock.Unlock()
+
+	if oggFile != nil {
+		oggFile.Close()
+	}
+	if streamclient != nil {
+		streamclient.Close()
+	}
+	if server != nil {
+		b.transcription_pool.Release(server)
+	}
+}
+
+// sendTaskRequest tells the transcription server which task to perform.
+func (b *Bot) sendTaskRequest(task string) error {
+	streamclient := b.stream()
+	if streamclient == nil {
+		return fmt.Errorf("not connected to a transcription server")
+	}
+	b.clientsMutex.Lock()
+	language := b.SourceLanguage
+	b.clientsMutex.Unlock()
+	task_req_json, err := json.Marshal(taskRequest{Task: task, Language: language})
+	if err != nil {
+		return err
+	}
+	return streamclient.SendTCPMessage(string(task_req_json))
+}
+
+func (b *Bot) Disconnect() {
+	b.closeStream()
 	if b.audioclient != nil {
 		b.client.Leave()
 	}
-	if b.en_caption != nil {
+	if b.source_caption != nil {
 		b.audioclient.Close()
 	}
-	if b.client != nil {
-		b.oggFile.Close()
-	}
 
 	b.clientsMutex.Lock()
 	for lang, cl := range b.clients {
@@ -447,11 +912,20 @@ func (b *Bot) Disconnect() {
 	for k := range b.clients {
 		delete(b.clients, k)
 	}
+	b.pad_writers = nil
+	for id, entry := range b.sinks {
+		if err := entry.sink.Close(); err != nil {
+			log.Printf("[WARN] Bot %s: failed to close sink %s: %v", b.ID, id, err)
+		}
+	}
+	b.sinks = nil
 	b.clientsMutex.Unlock()
 }
 
 type taskRequest struct {
 	Task string `json:"task"`
+	// Language the server should expect, ignored by older servers
+	Language string `json:"language,omitempty"`
 }
 
 func (b *Bot) Translate(
@@ -470,13 +944,27 @@ func (b *Bot) Translate(
 		return fmt.Errorf("bot is not in translate mode")
 	}
 
-	// check if language is already in use
-	if _, ok := b.clients[targetLang]; ok {
-		b.clients[targetLang].Leave()
+	if targetLang == b.SourceLanguage {
+		return fmt.Errorf("%s is the source language", targetLang)
+	}
+
+	// check if language is already in use. The old client and capture are
+	// disconnected without the lock, their callbacks take it.
+	b.clientsMutex.Lock()
+	old_client, inUse := b.clients[targetLang]
+	old_capture, hasCapture := b.captures[targetLang]
+	if inUse {
 		delete(b.clients, targetLang)
-		if capture, ok := b.captures[targetLang]; ok {
-			capture.Disconnect()
+		if hasCapture {
 			delete(b.captures, targetLang)
+			delete(b.pad_writers, old_capture)
+		}
+	}
+	b.clientsMutex.Unlock()
+	if inUse {
+		old_client.Leave()
+		if hasCapture {
+			old_capture.Disconnect()
 		}
 	}
 
@@ -504,7 +992,15 @@ func (b *Bot) Translate(
 	}
 
 	new_capture.OnDisconnect(func() {
+		b.clientsMutex.Lock()
+		removed := b.captures[targetLang] != new_capture
+		b.clientsMutex.Unlock()
+		if removed {
+			// Dropped when leaving translate mode
+			return
+		}
 		log.Printf("New capture %s disconnected", targetLang)
+		b.webhooks.Emit(EventBotCaptionLost, b, map[string]any{"language": targetLang})
 		b.StopTranslate(targetLang)
 	})
 
@@ -526,9 +1022,30 @@ func (b *Bot) Translate(
 	}
 	b.clientsMutex.Unlock()
 
+	b.webhooks.Emit(EventBotLanguageAdded, b, map[string]any{"language": targetLang})
 	return nil
 }
 
+// updateStats refreshes the exported statistic fields before the bot is
+// returned by the API.
+func (b *Bot) updateStats() {
+	b.Sub_bots = len(b.clients)
+	b.Jitter = b.jitterbuffer.Stats()
+	b.Silence = b.silencegate.Stats()
+	b.UDP = UDPStats{}
+	b.Protocol = Capabilities{}
+	b.TranscriptionServer = ""
+	if streamclient := b.stream(); streamclient != nil {
+		b.UDP = streamclient.UDPStats()
+		b.Protocol = streamclient.Capabilities()
+	}
+	b.streamLock.Lock()
+	if b.server != nil {
+		b.TranscriptionServer = b.server.Address()
+	}
+	b.streamLock.Unlock()
+}
+
 func (b *Bot) GetAllActiveTranslations() []string {
 	return b.Languages
 }
@@ -536,7 +1053,7 @@ func (b *Bot) GetAllActiveTranslations() []string {
 func (b *Bot) StopTranslate(
 	targetLang string,
 ) error {
-	if targetLang == "en" {
+	if targetLang == b.SourceLanguage {
 		// switch to transcription mode
 		b.SetTask(TaskTranscribe)
 		return nil
@@ -552,6 +1069,7 @@ func (b *Bot) StopTranslate(
 		if capture, ok := b.captures[targetLang]; ok {
 			capture.Disconnect()
 			delete(b.captures, targetLang)
+			delete(b.pad_writers, capture)
 		}
 		// remove language from list
 		for i, lang := range b.Languages {
@@ -567,6 +1085,7 @@ func (b *Bot) StopTranslate(
 			}
 		}
 
+		b.webhooks.Emit(EventBotLanguageRemoved, b, map[string]any{"language": targetLang})
 		return nil
 	}
 	return fmt.Errorf("client not found")
@@ -577,21 +1096,37 @@ func (b *Bot) GetTask() Task {
 }
 
 func (b *Bot) SetTask(task Task) {
+	previous := b.Task
+	defer func() {
+		if b.Task != previous {
+			b.webhooks.Emit(EventBotTaskChanged, b, map[string]any{
+				"from": previous.String(),
+				"to":   b.Task.String(),
+			})
+		}
+	}()
+
 	if b.Task == TaskTranslate && task == TaskTranscribe {
-		// stop all clients
-		for _, cl := range b.clients {
+		// stop all clients and drop their captures, the transcript must
+		// not be written to them anymore
+		b.clientsMutex.Lock()
+		for lang, cl := range b.clients {
 			cl.Leave()
+			delete(b.clients, lang)
 		}
-
-		// send task to transcription server
-		task_req := taskRequest{
-			Task: "transcribe",
+		captures := make([]*pad.Pad, 0, len(b.captures))
+		for lang, capture := range b.captures {
+			captures = append(captures, capture)
+			delete(b.captures, lang)
+			delete(b.pad_writers, capture)
 		}
-		task_req_json, err := json.Marshal(task_req)
-		if err != nil {
-			return
+		b.clientsMutex.Unlock()
+		for _, capture := range captures {
+			capture.Disconnect()
 		}
-		err = b.streamclient.SendTCPMessage(string(task_req_json))
+
+		// send task to transcription server
+		err := b.sendTaskRequest("transcribe")
 		if err != nil {
 			return
 		}
@@ -599,15 +1134,7 @@ func (b *Bot) SetTask(task Task) {
 
 	if b.Task == TaskTranscribe && task == TaskTranslate {
 		// send task to transcription server
-		task_req := taskRequest{
-			Task: "translate",
-		}
-		task_req_json, err := json.Marshal(task_req)
-		if err != nil {
-			log.Println("Error in task request json:", err)
-			return
-		}
-		err = b.streamclient.SendTCPMessage(string(task_req_json))
+		err := b.sendTaskRequest("translate")
 		if err != nil {
 			log.Println("Error in task request send:", err)
 			return
@@ -624,8 +1151,8 @@ func (b *Bot) SetTask(task Task) {
 
 		// start all clients
 		for _, lang := range all_languages {
-			// skip en
-			if lang == "en" {
+			// skip the source language
+			if lang == b.SourceLanguage {
 				continue
 			}
 
@@ -639,3 +1166,80 @@ func (b *Bot) SetTask(task Task) {
 
 	b.Task = task
 }
+
+// createSourceCaption creates the capture the transcript is written to. The
+// bot leaves if it disconnects.
+func (b *Bot) createSourceCaption(lang string) (*pad.Pad, error) {
+	capture, err := b.client.CreateCapture(bbbbot.Language(lang), b.changeset_external, b.changeset_host, b.changeset_port)
+	if err != nil {
+		return nil, err
+	}
+	capture.OnDisconnect(func() {
+		b.clientsMutex.Lock()
+		replaced := b.source_caption != capture
+		b.clientsMutex.Unlock()
+		if replaced {
+			// Replaced by SetSourceLanguage
+			return
+		}
+		log.Printf("Source caption %s disconnected", lang)
+		b.webhooks.Emit(EventBotCaptionLost, b, map[string]any{"language": lang})
+		b.Disconnect()
+	})
+	return capture, nil
+}
+
+// SetSourceLanguage changes the spoken language. While in a meeting, the
+// transcript moves to a new capture and the transcription server is told
+// about the change.
+func (b *Bot) SetSourceLanguage(lang string) error {
+	b.clientsMutex.Lock()
+	current := b.SourceLanguage
+	_, translated := b.clients[lang]
+	hasCaption := b.source_caption != nil
+	b.clientsMutex.Unlock()
+
+	if lang == current {
+		return nil
+	}
+	if b.Status == Connecting {
+		return fmt.Errorf("bot is connecting")
+	}
+
+	// The new source can not be a translation target at the same time
+	if translated {
+		if err := b.StopTranslate(lang); err != nil {
+			return err
+		}
+	}
+
+	// The capture is created without the lock, it talks to the BBB server
+	if hasCaption && b.Status == Connected {
+		capture, err := b.createSourceCaption(lang)
+		if err != nil {
+			return err
+		}
+		b.clientsMutex.Lock()
+		old := b.source_caption
+		b.source_caption = capture
+		delete(b.pad_writers, old)
+		b.clientsMutex.Unlock()
+		old.Disconnect()
+	}
+
+	b.clientsMutex.Lock()
+	for i, l := range b.Languages {
+		if l == b.SourceLanguage {
+			b.Languages[i] = lang
+		}
+	}
+	b.SourceLanguage = lang
+	b.clientsMutex.Unlock()
+
+	if b.stream() != nil {
+		if err := b.sendTaskRequest(b.Task.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package botclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the bot API. The methods are generated from the OpenAPI spec
+// in client_gen.go.
+type Client struct {
+	// URL the API is served at, e.g. "http://localhost:8080"
+	BaseURL string
+	// Sent as X-API-Key, identifies the client for rate limits and quotas
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// APIError is returned for every response with a status code of 400 or above.
+type APIError struct {
+	StatusCode int
+	// Problem details sent by the API, if any
+	Model ErrorModel
+}
+
+func (e *APIError) Error() string {
+	if e.Model.Detail != "" {
+		return fmt.Sprintf("bot API: %d %s", e.StatusCode, e.Model.Detail)
+	}
+	return fmt.Sprintf("bot API: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// do sends a request. body is sent as JSON unless it is an io.Reader, out is
+// decoded from the JSON response if it is not nil. A *[]byte out receives the
+// response body as it is.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, contentType string, body any, out any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(&apiErr.Model)
+		return apiErr
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,564 @@
+// Code generated by botclient/gen from openapi.json. DO NOT EDIT.
+
+package botclient
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Attendee struct {
+	ClientType      string `json:"clientType"`
+	FullName        string `json:"fullName"`
+	HasJoinedVoice  bool   `json:"hasJoinedVoice"`
+	HasVideo        bool   `json:"hasVideo"`
+	IsListeningOnly bool   `json:"isListeningOnly"`
+	IsPresenter     bool   `json:"isPresenter"`
+	Role            string `json:"role"`
+	UserID          string `json:"userID"`
+}
+
+type Bot struct {
+	DetectedLanguage    string          `json:"detected_language,omitempty"`
+	ID                  string          `json:"id"`
+	Jitter              JitterStats     `json:"jitter"`
+	JoinedAt            time.Time       `json:"joined_at"`
+	Languages           []string        `json:"languages"`
+	MeetingID           string          `json:"meeting_id"`
+	Policy              LifecyclePolicy `json:"policy"`
+	Protocol            Capabilities    `json:"protocol"`
+	Silence             SilenceStats    `json:"silence"`
+	SourceLanguage      string          `json:"source_language"`
+	Status              int64           `json:"status"`
+	SubBots             int64           `json:"sub_bots"`
+	Task                int64           `json:"task"`
+	TranscriptionServer string          `json:"transcription_server"`
+	UDP                 UDPStats        `json:"udp"`
+	UserName            string          `json:"user_name"`
+}
+
+type Capabilities struct {
+	Cipher        string   `json:"cipher,omitempty"`
+	Features      []string `json:"features"`
+	Protocol      int64    `json:"protocol"`
+	ServerVersion string   `json:"server_version,omitempty"`
+}
+
+type CaptionSinkInfo struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+	// Only send captions of this language, all captioned languages if empty
+	Language string `json:"language,omitempty"`
+	// HMAC secret of webhook requests, generated if empty
+	Secret string `json:"secret,omitempty"`
+	// File name in CAPTION_SINK_DIR, or URL of the webhook or WebSocket on a host in CAPTION_SINK_HOSTS
+	Target string `json:"target"`
+	// Kind of destination
+	Type string `json:"type"`
+}
+
+type CaptionSinkSpec struct {
+	// Only send captions of this language, all captioned languages if empty
+	Language string `json:"language,omitempty"`
+	// HMAC secret of webhook requests, generated if empty
+	Secret string `json:"secret,omitempty"`
+	// File name in CAPTION_SINK_DIR, or URL of the webhook or WebSocket on a host in CAPTION_SINK_HOSTS
+	Target string `json:"target"`
+	// Kind of destination
+	Type string `json:"type"`
+}
+
+type DependencyStatus struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs float64   `json:"latency_ms"`
+	Name      string    `json:"name"`
+	Required  bool      `json:"required"`
+}
+
+type ErrorDetail struct {
+	// Where the error occurred, e.g. 'body.items[3].tags' or 'path.thing-id'
+	Location string `json:"location,omitempty"`
+	// Error message text
+	Message string `json:"message,omitempty"`
+	// The value at the given location
+	Value any `json:"value,omitempty"`
+}
+
+type ErrorModel struct {
+	// A human-readable explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Optional list of individual error details
+	Errors []ErrorDetail `json:"errors,omitempty"`
+	// A URI reference that identifies the specific occurrence of the problem.
+	Instance string `json:"instance,omitempty"`
+	// HTTP status code
+	Status int64 `json:"status,omitempty"`
+	// A short, human-readable summary of the problem type. This value should not change between occurrences of the error.
+	Title string `json:"title,omitempty"`
+	// A URI reference to human-readable documentation for the error.
+	Type string `json:"type,omitempty"`
+}
+
+type HLSRendition struct {
+	Language string    `json:"language"`
+	Media    string    `json:"media"`
+	Start    time.Time `json:"start"`
+	URI      string    `json:"uri"`
+}
+
+type HealthResponse struct {
+	Dependencies []DependencyStatus `json:"dependencies,omitempty"`
+	Status       string             `json:"status"`
+}
+
+type HistoryEntry struct {
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+type JitterStats struct {
+	Concealed   int64 `json:"concealed"`
+	Duplicates  int64 `json:"duplicates"`
+	Late        int64 `json:"late"`
+	Lost        int64 `json:"lost"`
+	Received    int64 `json:"received"`
+	Reordered   int64 `json:"reordered"`
+	Resyncs     int64 `json:"resyncs"`
+	SSRCChanges int64 `json:"ssrc_changes"`
+}
+
+type LifecyclePolicy struct {
+	// Minutes to wait for other participants before leaving
+	AloneGraceMinutes int64 `json:"alone_grace_minutes"`
+	// Leave after this many minutes without speech, 0 disables
+	IdleTimeoutMinutes int64 `json:"idle_timeout_minutes"`
+	// Leave when no other participant is in the meeting
+	LeaveWhenAlone bool `json:"leave_when_alone"`
+	// Leave after this many minutes, 0 disables
+	MaxDurationMinutes int64 `json:"max_duration_minutes"`
+}
+
+type LossReport struct {
+	Duplicates int64     `json:"duplicates"`
+	HighestSeq int32     `json:"highest_seq"`
+	Lost       int64     `json:"lost"`
+	Received   int64     `json:"received"`
+	ReceivedAt time.Time `json:"received_at"`
+	Reordered  int64     `json:"reordered"`
+}
+
+type Meeting struct {
+	AttendeePW            string     `json:"attendeePW"`
+	Attendees             []Attendee `json:"attendees"`
+	CreateDate            string     `json:"createDate"`
+	CreateTime            int64      `json:"createTime"`
+	DialNumber            string     `json:"dialNumber"`
+	Duration              int64      `json:"duration"`
+	EndTime               int64      `json:"endTime"`
+	HasBeenForciblyEnded  bool       `json:"hasBeenForciblyEnded"`
+	HasUserJoined         bool       `json:"hasUserJoined"`
+	InternalMeetingID     string     `json:"internalMeetingID"`
+	IsBreakout            bool       `json:"isBreakout"`
+	ListenerCount         int64      `json:"listenerCount"`
+	MaxUsers              int64      `json:"maxUsers"`
+	MeetingID             string     `json:"meetingID"`
+	MeetingName           string     `json:"meetingName"`
+	Metadata              Metadata   `json:"metadata"`
+	ModeratorCount        int64      `json:"moderatorCount"`
+	ModeratorPW           string     `json:"moderatorPW"`
+	ParticipantCount      int64      `json:"participantCount"`
+	Recording             bool       `json:"recording"`
+	Running               bool       `json:"running"`
+	StartTime             int64      `json:"startTime"`
+	VideoCount            int64      `json:"videoCount"`
+	VoiceBridge           int64      `json:"voiceBridge"`
+	VoiceParticipantCount int64      `json:"voiceParticipantCount"`
+}
+
+type Metadata struct {
+	BBBOrigin           string `json:"bbbOrigin"`
+	BBBOriginServerName string `json:"bbbOriginServerName"`
+	BBBOriginVersion    string `json:"bbbOriginVersion"`
+	GlListed            bool   `json:"glListed"`
+}
+
+type Schedule struct {
+	BotID string `json:"bot_id,omitempty"`
+	// Start times of a recurring window, e.g. '0 10 * * 1-5'
+	Cron string `json:"cron,omitempty"`
+	// Length of a recurring window in minutes
+	DurationMinutes int64 `json:"duration_minutes,omitempty"`
+	// End of a one-time window
+	End time.Time `json:"end,omitempty"`
+	ID  string    `json:"id"`
+	// Languages to translate to
+	Languages  []string  `json:"languages,omitempty"`
+	LastWindow time.Time `json:"last_window,omitempty"`
+	// Meeting ID to join
+	MeetingID string `json:"meeting_id,omitempty"`
+	// Join the first meeting whose name matches this glob pattern, e.g. 'Lecture *'
+	NamePattern string `json:"name_pattern,omitempty"`
+	// Start of a one-time window
+	Start time.Time `json:"start,omitempty"`
+	// Task type
+	Task string `json:"task"`
+}
+
+type ScheduleSpec struct {
+	// Start times of a recurring window, e.g. '0 10 * * 1-5'
+	Cron string `json:"cron,omitempty"`
+	// Length of a recurring window in minutes
+	DurationMinutes int64 `json:"duration_minutes,omitempty"`
+	// End of a one-time window
+	End time.Time `json:"end,omitempty"`
+	// Languages to translate to
+	Languages []string `json:"languages,omitempty"`
+	// Meeting ID to join
+	MeetingID string `json:"meeting_id,omitempty"`
+	// Join the first meeting whose name matches this glob pattern, e.g. 'Lecture *'
+	NamePattern string `json:"name_pattern,omitempty"`
+	// Start of a one-time window
+	Start time.Time `json:"start,omitempty"`
+	// Task type
+	Task string `json:"task"`
+}
+
+type SilenceStats struct {
+	Enabled      bool  `json:"enabled"`
+	Forwarded    int64 `json:"forwarded"`
+	Speaking     bool  `json:"speaking"`
+	Suppressed   int64 `json:"suppressed"`
+	SuppressedMs int64 `json:"suppressed_ms"`
+}
+
+type StatusResponse struct {
+	BotsCount int64 `json:"bots_count"`
+	MaxBots   int64 `json:"max_bots"`
+}
+
+type TranscriptionServer struct {
+	Bots            int64     `json:"bots"`
+	HealthCheckPort int64     `json:"health_check_port"`
+	Healthy         bool      `json:"healthy"`
+	Host            string    `json:"host"`
+	LastCheck       time.Time `json:"last_check"`
+	Port            int64     `json:"port"`
+}
+
+type UDPStats struct {
+	Bytes     int64      `json:"bytes"`
+	LastSeq   int32      `json:"last_seq"`
+	Report    LossReport `json:"report,omitempty"`
+	Sent      int64      `json:"sent"`
+	Sequenced bool       `json:"sequenced"`
+}
+
+type WebhookDelivery struct {
+	Attempt        int64     `json:"attempt"`
+	DurationMs     float64   `json:"duration_ms"`
+	Error          string    `json:"error,omitempty"`
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	StatusCode     int64     `json:"status_code,omitempty"`
+	SubscriptionID string    `json:"subscription_id"`
+	Success        bool      `json:"success"`
+	Time           time.Time `json:"time"`
+}
+
+type WebhookSpec struct {
+	// Event types to send, all if empty
+	Events []string `json:"events,omitempty"`
+	// HMAC secret, generated if empty
+	Secret string `json:"secret,omitempty"`
+	// URL the events are POSTed to, on a host in WEBHOOK_HOSTS
+	URL string `json:"url"`
+}
+
+type WebhookSubscription struct {
+	CreatedAt time.Time `json:"created_at"`
+	// Event types to send, all if empty
+	Events []string `json:"events,omitempty"`
+	ID     string   `json:"id"`
+	// HMAC secret, generated if empty
+	Secret string `json:"secret,omitempty"`
+	// URL the events are POSTed to, on a host in WEBHOOK_HOSTS
+	URL string `json:"url"`
+}
+
+// GetLanguages calls GET /api/v1/bbb/languages: List supported languages
+func (c *Client) GetLanguages(ctx context.Context) (map[string]string, error) {
+	var out map[string]string
+	err := c.do(ctx, "GET", "/api/v1/bbb/languages", nil, "", nil, &out)
+	return out, err
+}
+
+// DeleteMeeting calls DELETE /api/v1/bbb/meeting/{meeting_id}: End a meeting
+func (c *Client) DeleteMeeting(ctx context.Context, meetingID string) error {
+	return c.do(ctx, "DELETE", "/api/v1/bbb/meeting/"+url.PathEscape(meetingID), nil, "", nil, nil)
+}
+
+// GetMeeting calls GET /api/v1/bbb/meeting/{meeting_id}: Get a single meeting
+func (c *Client) GetMeeting(ctx context.Context, meetingID string) (Meeting, error) {
+	var out Meeting
+	err := c.do(ctx, "GET", "/api/v1/bbb/meeting/"+url.PathEscape(meetingID), nil, "", nil, &out)
+	return out, err
+}
+
+// GetMeetings calls GET /api/v1/bbb/meetings: List all BBB meetings
+func (c *Client) GetMeetings(ctx context.Context) ([]Meeting, error) {
+	var out []Meeting
+	err := c.do(ctx, "GET", "/api/v1/bbb/meetings", nil, "", nil, &out)
+	return out, err
+}
+
+// BBBWebhookParams are the query parameters of BBBWebhook.
+type BBBWebhookParams struct {
+	// BBB checksum
+	Checksum string
+}
+
+// BBBWebhook calls POST /api/v1/bbb/webhook: Callback for BBB server webhooks
+func (c *Client) BBBWebhook(ctx context.Context, params BBBWebhookParams, body io.Reader) error {
+	query := url.Values{}
+	if params.Checksum != "" {
+		query.Set("checksum", params.Checksum)
+	}
+	return c.do(ctx, "POST", "/api/v1/bbb/webhook", query, "application/x-www-form-urlencoded", body, nil)
+}
+
+// BotJoinParams are the query parameters of BotJoin.
+type BotJoinParams struct {
+	// Language spoken in the meeting, defaults to BOT_SOURCE_LANGUAGE
+	SourceLanguage string
+}
+
+// BotJoin calls POST /api/v1/bot/join/{meeting_id}: Create a bot and join a meeting
+func (c *Client) BotJoin(ctx context.Context, meetingID string, params BotJoinParams) (Bot, error) {
+	query := url.Values{}
+	if params.SourceLanguage != "" {
+		query.Set("source_language", params.SourceLanguage)
+	}
+	var out Bot
+	err := c.do(ctx, "POST", "/api/v1/bot/join/"+url.PathEscape(meetingID), query, "", nil, &out)
+	return out, err
+}
+
+// GetBot calls GET /api/v1/bot/{bot_id}: Get bot details
+func (c *Client) GetBot(ctx context.Context, botID string) (Bot, error) {
+	var out Bot
+	err := c.do(ctx, "GET", "/api/v1/bot/"+url.PathEscape(botID), nil, "", nil, &out)
+	return out, err
+}
+
+// GetBotHistoryParams are the query parameters of GetBotHistory.
+type GetBotHistoryParams struct {
+	// Only return this language
+	Lang string
+}
+
+// GetBotHistory calls GET /api/v1/bot/{bot_id}/history: Get the caption text trimmed from the pads
+func (c *Client) GetBotHistory(ctx context.Context, botID string, params GetBotHistoryParams) (map[string][]HistoryEntry, error) {
+	query := url.Values{}
+	if params.Lang != "" {
+		query.Set("lang", params.Lang)
+	}
+	var out map[string][]HistoryEntry
+	err := c.do(ctx, "GET", "/api/v1/bot/"+url.PathEscape(botID)+"/history", query, "", nil, &out)
+	return out, err
+}
+
+// GetBotHls calls GET /api/v1/bot/{bot_id}/hls: List the WebVTT subtitle renditions of the captions
+func (c *Client) GetBotHls(ctx context.Context, botID string) ([]HLSRendition, error) {
+	var out []HLSRendition
+	err := c.do(ctx, "GET", "/api/v1/bot/"+url.PathEscape(botID)+"/hls", nil, "", nil, &out)
+	return out, err
+}
+
+// GetBotHlsPlaylistParams are the query parameters of GetBotHlsPlaylist.
+type GetBotHlsPlaylistParams struct {
+	// MPEG-TS timestamp (90 kHz) of the video at the start of the rendition, passed on to the segments
+	Mpegts int64
+}
+
+// GetBotHlsPlaylist calls GET /api/v1/bot/{bot_id}/hls/{lang}/playlist.m3u8: Get the live WebVTT subtitle playlist of a language
+func (c *Client) GetBotHlsPlaylist(ctx context.Context, botID string, lang string, params GetBotHlsPlaylistParams) ([]byte, error) {
+	query := url.Values{}
+	if params.Mpegts != 0 {
+		query.Set("mpegts", strconv.FormatInt(int64(params.Mpegts), 10))
+	}
+	var out []byte
+	err := c.do(ctx, "GET", "/api/v1/bot/"+url.PathEscape(botID)+"/hls/"+url.PathEscape(lang)+"/playlist.m3u8", query, "", nil, &out)
+	return out, err
+}
+
+// GetBotHlsSegmentParams are the query parameters of GetBotHlsSegment.
+type GetBotHlsSegmentParams struct {
+	// MPEG-TS timestamp (90 kHz) of the video at the start of the rendition
+	Mpegts int64
+}
+
+// GetBotHlsSegment calls GET /api/v1/bot/{bot_id}/hls/{lang}/{segment}: Get a WebVTT subtitle segment
+func (c *Client) GetBotHlsSegment(ctx context.Context, botID string, lang string, segment string, params GetBotHlsSegmentParams) ([]byte, error) {
+	query := url.Values{}
+	if params.Mpegts != 0 {
+		query.Set("mpegts", strconv.FormatInt(int64(params.Mpegts), 10))
+	}
+	var out []byte
+	err := c.do(ctx, "GET", "/api/v1/bot/"+url.PathEscape(botID)+"/hls/"+url.PathEscape(lang)+"/"+url.PathEscape(segment), query, "", nil, &out)
+	return out, err
+}
+
+// BotLeave calls POST /api/v1/bot/{bot_id}/leave: Bot leaves its meeting
+func (c *Client) BotLeave(ctx context.Context, botID string) error {
+	return c.do(ctx, "POST", "/api/v1/bot/"+url.PathEscape(botID)+"/leave", nil, "", nil, nil)
+}
+
+// BotSetPolicy calls PUT /api/v1/bot/{bot_id}/policy: Set when the bot leaves its meeting on its own
+func (c *Client) BotSetPolicy(ctx context.Context, botID string, body LifecyclePolicy) (LifecyclePolicy, error) {
+	var out LifecyclePolicy
+	err := c.do(ctx, "PUT", "/api/v1/bot/"+url.PathEscape(botID)+"/policy", nil, "application/json", body, &out)
+	return out, err
+}
+
+// DeleteBotSink calls DELETE /api/v1/bot/{bot_id}/sink/{sink_id}: Stop sending the captions to a destination
+func (c *Client) DeleteBotSink(ctx context.Context, botID string, sinkID string) error {
+	return c.do(ctx, "DELETE", "/api/v1/bot/"+url.PathEscape(botID)+"/sink/"+url.PathEscape(sinkID), nil, "", nil, nil)
+}
+
+// GetBotSinks calls GET /api/v1/bot/{bot_id}/sinks: List the additional destinations of the captions
+func (c *Client) GetBotSinks(ctx context.Context, botID string) ([]CaptionSinkInfo, error) {
+	var out []CaptionSinkInfo
+	err := c.do(ctx, "GET", "/api/v1/bot/"+url.PathEscape(botID)+"/sinks", nil, "", nil, &out)
+	return out, err
+}
+
+// CreateBotSink calls POST /api/v1/bot/{bot_id}/sinks: Send the captions to another destination
+func (c *Client) CreateBotSink(ctx context.Context, botID string, body CaptionSinkSpec) (CaptionSinkInfo, error) {
+	var out CaptionSinkInfo
+	err := c.do(ctx, "POST", "/api/v1/bot/"+url.PathEscape(botID)+"/sinks", nil, "application/json", body, &out)
+	return out, err
+}
+
+// BotSetSourceLanguage calls PUT /api/v1/bot/{bot_id}/source/{lang}: Set the language spoken in the meeting
+func (c *Client) BotSetSourceLanguage(ctx context.Context, botID string, lang string) (Bot, error) {
+	var out Bot
+	err := c.do(ctx, "PUT", "/api/v1/bot/"+url.PathEscape(botID)+"/source/"+url.PathEscape(lang), nil, "", nil, &out)
+	return out, err
+}
+
+// BotSetTask calls PUT /api/v1/bot/{bot_id}/task/{task}: Set bot task (transcribe/translate)
+func (c *Client) BotSetTask(ctx context.Context, botID string, task string) error {
+	return c.do(ctx, "PUT", "/api/v1/bot/"+url.PathEscape(botID)+"/task/"+url.PathEscape(task), nil, "", nil, nil)
+}
+
+// BotTranslateStop calls DELETE /api/v1/bot/{bot_id}/translate/{lang}: Stop translation
+func (c *Client) BotTranslateStop(ctx context.Context, botID string, lang string) error {
+	return c.do(ctx, "DELETE", "/api/v1/bot/"+url.PathEscape(botID)+"/translate/"+url.PathEscape(lang), nil, "", nil, nil)
+}
+
+// BotTranslateStart calls PUT /api/v1/bot/{bot_id}/translate/{lang}: Start translation
+func (c *Client) BotTranslateStart(ctx context.Context, botID string, lang string) error {
+	return c.do(ctx, "PUT", "/api/v1/bot/"+url.PathEscape(botID)+"/translate/"+url.PathEscape(lang), nil, "", nil, nil)
+}
+
+// GetBots calls GET /api/v1/bots: List all bots
+func (c *Client) GetBots(ctx context.Context) (map[string]Bot, error) {
+	var out map[string]Bot
+	err := c.do(ctx, "GET", "/api/v1/bots", nil, "", nil, &out)
+	return out, err
+}
+
+// DeleteSchedule calls DELETE /api/v1/schedule/{schedule_id}: Delete a scheduled bot join
+func (c *Client) DeleteSchedule(ctx context.Context, scheduleID string) error {
+	return c.do(ctx, "DELETE", "/api/v1/schedule/"+url.PathEscape(scheduleID), nil, "", nil, nil)
+}
+
+// GetSchedule calls GET /api/v1/schedule/{schedule_id}: Get a scheduled bot join
+func (c *Client) GetSchedule(ctx context.Context, scheduleID string) (Schedule, error) {
+	var out Schedule
+	err := c.do(ctx, "GET", "/api/v1/schedule/"+url.PathEscape(scheduleID), nil, "", nil, &out)
+	return out, err
+}
+
+// GetSchedules calls GET /api/v1/schedules: List scheduled bot joins
+func (c *Client) GetSchedules(ctx context.Context) ([]Schedule, error) {
+	var out []Schedule
+	err := c.do(ctx, "GET", "/api/v1/schedules", nil, "", nil, &out)
+	return out, err
+}
+
+// CreateSchedule calls POST /api/v1/schedules: Schedule a bot to join a meeting
+func (c *Client) CreateSchedule(ctx context.Context, body ScheduleSpec) (Schedule, error) {
+	var out Schedule
+	err := c.do(ctx, "POST", "/api/v1/schedules", nil, "application/json", body, &out)
+	return out, err
+}
+
+// GetStatus calls GET /api/v1/status: Get server status
+func (c *Client) GetStatus(ctx context.Context) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.do(ctx, "GET", "/api/v1/status", nil, "", nil, &out)
+	return out, err
+}
+
+// GetTranscriptionServers calls GET /api/v1/transcription/servers: List transcription servers and their load
+func (c *Client) GetTranscriptionServers(ctx context.Context) ([]TranscriptionServer, error) {
+	var out []TranscriptionServer
+	err := c.do(ctx, "GET", "/api/v1/transcription/servers", nil, "", nil, &out)
+	return out, err
+}
+
+// DeleteWebhook calls DELETE /api/v1/webhook/{webhook_id}: Delete a webhook subscription
+func (c *Client) DeleteWebhook(ctx context.Context, webhookID string) error {
+	return c.do(ctx, "DELETE", "/api/v1/webhook/"+url.PathEscape(webhookID), nil, "", nil, nil)
+}
+
+// GetWebhooks calls GET /api/v1/webhooks: List webhook subscriptions
+func (c *Client) GetWebhooks(ctx context.Context) ([]WebhookSubscription, error) {
+	var out []WebhookSubscription
+	err := c.do(ctx, "GET", "/api/v1/webhooks", nil, "", nil, &out)
+	return out, err
+}
+
+// CreateWebhook calls POST /api/v1/webhooks: Subscribe to bot lifecycle events
+func (c *Client) CreateWebhook(ctx context.Context, body WebhookSpec) (WebhookSubscription, error) {
+	var out WebhookSubscription
+	err := c.do(ctx, "POST", "/api/v1/webhooks", nil, "application/json", body, &out)
+	return out, err
+}
+
+// GetWebhookDeliveriesParams are the query parameters of GetWebhookDeliveries.
+type GetWebhookDeliveriesParams struct {
+	// Only deliveries of this webhook
+	WebhookID string
+}
+
+// GetWebhookDeliveries calls GET /api/v1/webhooks/deliveries: List the latest webhook delivery attempts
+func (c *Client) GetWebhookDeliveries(ctx context.Context, params GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	query := url.Values{}
+	if params.WebhookID != "" {
+		query.Set("webhook_id", params.WebhookID)
+	}
+	var out []WebhookDelivery
+	err := c.do(ctx, "GET", "/api/v1/webhooks/deliveries", query, "", nil, &out)
+	return out, err
+}
+
+// GetHealthz calls GET /healthz: Liveness probe
+func (c *Client) GetHealthz(ctx context.Context) (HealthResponse, error) {
+	var out HealthResponse
+	err := c.do(ctx, "GET", "/healthz", nil, "", nil, &out)
+	return out, err
+}
+
+// GetReadyz calls GET /readyz: Readiness probe with the status of all dependencies
+func (c *Client) GetReadyz(ctx context.Context) (HealthResponse, error) {
+	var out HealthResponse
+	err := c.do(ctx, "GET", "/readyz", nil, "", nil, &out)
+	return out, err
+}
@@ -0,0 +1,11 @@
+// Package botclient is a Go client for the bot REST API.
+//
+// client_gen.go is generated from openapi.json, which is the spec of the
+// routes registered by addRoutes. After changing the API run
+//
+//	make generate-client
+//
+// and commit both files. make check-client fails if they are out of date.
+package botclient
+
+//go:generate go run ./gen -spec openapi.json -out client_gen.go
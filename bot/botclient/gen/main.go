@@ -0,0 +1,437 @@
+// Command gen writes the types and methods of the bot API client from its
+// OpenAPI spec. It only supports what huma generates for the bot API.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 any                `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties any                `json:"additionalProperties"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"responses"`
+}
+
+type spec struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Words which are written in upper case in Go names
+var initialisms = map[string]bool{
+	"api": true, "bbb": true, "id": true, "pw": true, "ssrc": true,
+	"udp": true, "uri": true, "url": true,
+}
+
+func main() {
+	specFile := flag.String("spec", "openapi.json", "OpenAPI spec to read")
+	out := flag.String("out", "client_gen.go", "File to write")
+	check := flag.Bool("check", false, "Fail if the file is not up to date instead of writing it")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var s spec
+	if err := json.Unmarshal(data, &s); err != nil {
+		log.Fatalf("could not parse %s: %v", *specFile, err)
+	}
+
+	source, err := generate(&s)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *check {
+		existing, err := os.ReadFile(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !bytes.Equal(existing, source) {
+			log.Fatalf("%s is out of date, run 'make generate-client'", *out)
+		}
+		return
+	}
+	if err := os.WriteFile(*out, source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(s *spec) ([]byte, error) {
+	var b bytes.Buffer
+
+	names := make([]string, 0, len(s.Components.Schemas))
+	for name := range s.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeType(&b, name, s.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	paths := make([]string, 0, len(s.Paths))
+	for path := range s.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		methods := make([]string, 0, len(s.Paths[path]))
+		for method := range s.Paths[path] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			op := s.Paths[path][method]
+			if err := writeOperation(&b, path, strings.ToUpper(method), op); err != nil {
+				return nil, fmt.Errorf("operation %s: %w", op.OperationID, err)
+			}
+		}
+	}
+
+	// Only import what the generated code uses
+	body := b.String()
+	var file bytes.Buffer
+	file.WriteString("// Code generated by botclient/gen from openapi.json. DO NOT EDIT.\n\n")
+	file.WriteString("package botclient\n\nimport (\n")
+	imports := []struct{ pkg, use string }{
+		{"context", "context.Context"},
+		{"io", "io.Reader"},
+		{"net/url", "url."},
+		{"strconv", "strconv."},
+		{"time", "time.Time"},
+	}
+	for _, i := range imports {
+		if strings.Contains(body, i.use) {
+			fmt.Fprintf(&file, "%q\n", i.pkg)
+		}
+	}
+	file.WriteString(")\n\n")
+	file.WriteString(body)
+
+	source, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return source, nil
+}
+
+func writeType(b *bytes.Buffer, name string, s *schema) error {
+	if s.Description != "" {
+		writeComment(b, name+" "+s.Description)
+	}
+	if s.Properties == nil {
+		typ, err := goType(s)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "type %s %s\n\n", name, typ)
+		return nil
+	}
+
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	props := make([]string, 0, len(s.Properties))
+	for prop := range s.Properties {
+		// Added by huma to every response
+		if prop == "$schema" {
+			continue
+		}
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	for _, prop := range props {
+		p := s.Properties[prop]
+		typ, err := goType(p)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", prop, err)
+		}
+		tag := prop
+		if !contains(s.Required, prop) {
+			tag += ",omitempty"
+		}
+		if p.Description != "" {
+			writeComment(b, p.Description)
+		}
+		fmt.Fprintf(b, "%s %s `json:%q`\n", goName(prop), typ, tag)
+	}
+	b.WriteString("}\n\n")
+	return nil
+}
+
+func writeOperation(b *bytes.Buffer, path string, method string, op *operation) error {
+	name := goName(op.OperationID)
+
+	// Path parameters are arguments, query parameters go in a struct
+	args := []string{"ctx context.Context"}
+	pathExpr := fmt.Sprintf("%q", path)
+	query := make([]parameter, 0)
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			v := goVarName(param.Name)
+			args = append(args, v+" string")
+			pathExpr = strings.Replace(pathExpr, "{"+param.Name+"}", `"+url.PathEscape(`+v+`)+"`, 1)
+		case "query":
+			query = append(query, param)
+		default:
+			return fmt.Errorf("unsupported parameter location %s", param.In)
+		}
+	}
+	pathExpr = strings.TrimSuffix(strings.TrimPrefix(pathExpr, `""+`), `+""`)
+
+	if len(query) > 0 {
+		fmt.Fprintf(b, "// %sParams are the query parameters of %s.\n", name, name)
+		fmt.Fprintf(b, "type %sParams struct {\n", name)
+		for _, param := range query {
+			typ, err := goType(param.Schema)
+			if err != nil {
+				return err
+			}
+			if param.Schema.Description != "" {
+				writeComment(b, param.Schema.Description)
+			}
+			fmt.Fprintf(b, "%s %s\n", goName(param.Name), typ)
+		}
+		b.WriteString("}\n\n")
+		args = append(args, "params "+name+"Params")
+	}
+
+	contentType := ""
+	bodyExpr := "nil"
+	if op.RequestBody != nil {
+		for ct, media := range op.RequestBody.Content {
+			contentType = ct
+			if ct == "application/json" {
+				typ, err := goType(media.Schema)
+				if err != nil {
+					return err
+				}
+				args = append(args, "body "+typ)
+			} else {
+				args = append(args, "body io.Reader")
+			}
+			bodyExpr = "body"
+		}
+	}
+
+	result := ""
+	for code, resp := range op.Responses {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		if media, ok := resp.Content["application/json"]; ok {
+			typ, err := goType(media.Schema)
+			if err != nil {
+				return err
+			}
+			result = typ
+		} else if len(resp.Content) > 0 {
+			// Other content types are returned as they are
+			result = "[]byte"
+		}
+	}
+
+	comment := fmt.Sprintf("%s calls %s %s", name, method, path)
+	if op.Summary != "" {
+		comment += ": " + op.Summary
+	}
+	writeComment(b, comment)
+	if result == "" {
+		fmt.Fprintf(b, "func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), result)
+	}
+
+	queryExpr := "nil"
+	if len(query) > 0 {
+		queryExpr = "query"
+		b.WriteString("query := url.Values{}\n")
+		for _, param := range query {
+			field := "params." + goName(param.Name)
+			switch schemaType(param.Schema) {
+			case "string":
+				fmt.Fprintf(b, "if %s != \"\" {\nquery.Set(%q, %s)\n}\n", field, param.Name, field)
+			case "integer":
+				fmt.Fprintf(b, "if %s != 0 {\nquery.Set(%q, strconv.FormatInt(int64(%s), 10))\n}\n", field, param.Name, field)
+			case "boolean":
+				fmt.Fprintf(b, "if %s {\nquery.Set(%q, \"true\")\n}\n", field, param.Name)
+			default:
+				return fmt.Errorf("unsupported query parameter type of %s", param.Name)
+			}
+		}
+	}
+
+	if result == "" {
+		fmt.Fprintf(b, "return c.do(ctx, %q, %s, %s, %q, %s, nil)\n}\n\n", method, pathExpr, queryExpr, contentType, bodyExpr)
+		return nil
+	}
+	fmt.Fprintf(b, "var out %s\n", result)
+	fmt.Fprintf(b, "err := c.do(ctx, %q, %s, %s, %q, %s, &out)\n", method, pathExpr, queryExpr, contentType, bodyExpr)
+	b.WriteString("return out, err\n}\n\n")
+	return nil
+}
+
+// goType returns the Go type of a schema.
+func goType(s *schema) (string, error) {
+	if s.Ref != "" {
+		return s.Ref[strings.LastIndex(s.Ref, "/")+1:], nil
+	}
+	switch schemaType(s) {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "time.Time", nil
+		case "binary":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int32" {
+			return "int32", nil
+		}
+		return "int64", nil
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := goType(s.Items)
+		return "[]" + item, err
+	case "object":
+		if additional, ok := s.AdditionalProperties.(map[string]any); ok {
+			data, _ := json.Marshal(additional)
+			var value schema
+			if err := json.Unmarshal(data, &value); err != nil {
+				return "", err
+			}
+			typ, err := goType(&value)
+			return "map[string]" + typ, err
+		}
+		return "map[string]any", nil
+	case "":
+		// Any JSON value
+		return "any", nil
+	}
+	return "", fmt.Errorf("unsupported type %v", s.Type)
+}
+
+// schemaType returns the type of a schema, ignoring "null" in type lists.
+func schemaType(s *schema) string {
+	switch t := s.Type.(type) {
+	case string:
+		return t
+	case []any:
+		for _, v := range t {
+			if v != "null" {
+				return fmt.Sprint(v)
+			}
+		}
+	}
+	return ""
+}
+
+// goName turns snake_case, kebab-case and camelCase names into exported Go
+// names, e.g. "meeting_id" and "meetingID" into "MeetingID".
+func goName(name string) string {
+	var b strings.Builder
+	for _, word := range splitWords(name) {
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// goVarName returns the unexported Go name, e.g. "botID" for "bot_id".
+func goVarName(name string) string {
+	words := splitWords(name)
+	return strings.ToLower(words[0]) + goName(strings.Join(words[1:], "_"))
+}
+
+func splitWords(name string) []string {
+	words := make([]string, 0)
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' || r == '-' {
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]) && len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+func writeComment(b *bytes.Buffer, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		b.WriteString("// " + line + "\n")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
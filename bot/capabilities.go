@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	clientVersion = "1.0.0"
+
+	// Protocol version of the hello exchange. Servers announce their version in
+	// init_udpaddr, older servers don't and are treated as version 1.
+	protocolVersion = 2
+
+	helloTimeout = 5 * time.Second
+)
+
+// Features the bot can negotiate with the transcription server
+const (
+	FeatureUDPSequence = "udp_sequence"
+	FeatureLossReport  = "loss_report"
+	// The server sends "language" messages when the spoken language changes
+	FeatureLanguageDetection = "language_detection"
+)
+
+var clientFeatures = []string{
+	FeatureUDPSequence,
+	FeatureLossReport,
+	FeatureLanguageDetection,
+}
+
+// Capabilities describes what has been negotiated with the transcription
+// server.
+type Capabilities struct {
+	Protocol      int        `json:"protocol"`
+	ServerVersion string     `json:"server_version,omitempty"`
+	Cipher        CipherMode `json:"cipher,omitempty"`
+	Features      []string   `json:"features"`
+}
+
+// Has reports whether the server agreed to use the feature.
+func (c Capabilities) Has(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+type helloMessage struct {
+	Type string    `json:"type"`
+	Msg  helloBody `json:"msg"`
+}
+
+type helloBody struct {
+	Version  string   `json:"version"`
+	Protocol int      `json:"protocol"`
+	Features []string `json:"features"`
+}
+
+// negotiate sends our hello with all supported features and waits for the
+// server to reply with the features it has chosen.
+func (sc *StreamClient) negotiate() (Capabilities, error) {
+	reply := make(chan helloBody, 1)
+
+	var onhello func(message string)
+	onhello = func(message string) {
+		msgtype, err := sc.getMessageType(message)
+		if err != nil || msgtype != HELLO {
+			return
+		}
+		var hello helloMessage
+		if err := json.Unmarshal([]byte(message), &hello); err != nil {
+			log.Printf("[WARN] Failed to unmarshal hello: %v", err)
+			return
+		}
+		sc.tcpClient.RemoveOnMessage(onhello)
+		reply <- hello.Msg
+	}
+	sc.tcpClient.OnMessage(onhello)
+	defer sc.tcpClient.RemoveOnMessage(onhello)
+
+	hello, err := json.Marshal(helloMessage{
+		Type: "hello",
+		Msg: helloBody{
+			Version:  clientVersion,
+			Protocol: protocolVersion,
+			Features: clientFeatures,
+		},
+	})
+	if err != nil {
+		return Capabilities{}, err
+	}
+	if err := sc.tcpClient.Send(string(hello)); err != nil {
+		return Capabilities{}, err
+	}
+
+	select {
+	case body := <-reply:
+		// Only accept features we offered
+		features := make([]string, 0, len(body.Features))
+		offered := Capabilities{Features: clientFeatures}
+		for _, f := range body.Features {
+			if offered.Has(f) {
+				features = append(features, f)
+			}
+		}
+		return Capabilities{
+			Protocol:      body.Protocol,
+			ServerVersion: body.Version,
+			Cipher:        sc.tcpClient.GetCipherMode(),
+			Features:      features,
+		}, nil
+	case <-time.After(helloTimeout):
+		return Capabilities{}, fmt.Errorf("no hello reply from server within %s", helloTimeout)
+	}
+}
+
+// Capabilities returns what has been negotiated with the server.
+func (sc *StreamClient) Capabilities() Capabilities {
+	sc.capabilitiesLock.Lock()
+	defer sc.capabilitiesLock.Unlock()
+
+	return sc.capabilities
+}
+
+// HasFeature reports whether the server agreed to use the feature.
+func (sc *StreamClient) HasFeature(feature string) bool {
+	return sc.Capabilities().Has(feature)
+}
+
+func (sc *StreamClient) setCapabilities(capabilities Capabilities) {
+	sc.capabilitiesLock.Lock()
+	defer sc.capabilitiesLock.Unlock()
+
+	sc.capabilities = capabilities
+}
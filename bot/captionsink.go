@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Captions waiting for a webhook or WebSocket sink. When it can't keep up,
+// newer captions are dropped.
+const captionQueueLength = 100
+
+const captionSinkTimeout = 10 * time.Second
+
+// Caption is one update of the caption text of a language.
+type Caption struct {
+	BotID     string `json:"bot_id"`
+	MeetingID string `json:"meeting_id,omitempty"`
+	Language  string `json:"language"`
+	// Empty if the text replaces the whole caption, otherwise the text
+	// replaces the segment with this ID
+	SegmentID string    `json:"segment_id,omitempty"`
+	Text      string    `json:"text"`
+	Final     bool      `json:"final"`
+	Time      time.Time `json:"time"`
+}
+
+// CaptionSink is a destination for the captions of a bot. Writes may happen
+// concurrently.
+type CaptionSink interface {
+	WriteCaption(caption Caption) error
+	Close() error
+}
+
+// CaptionSinkConfig is shared by all caption sinks.
+type CaptionSinkConfig struct {
+	// Directory file sinks write to
+	Dir string
+	// Hosts webhook and WebSocket sinks may connect to. Sinks are created by
+	// API clients, without the list they could make the bot send requests to
+	// any server, including internal ones.
+	Hosts []string
+	// Max sinks per bot, 0 means unlimited
+	MaxPerBot int
+}
+
+// allowsHost reports whether sinks may connect to the host of u. An entry
+// "*.example.com" allows all subdomains of example.com.
+func (c CaptionSinkConfig) allowsHost(u *url.URL) bool {
+	return hostAllowed(c.Hosts, u)
+}
+
+// CaptionSinkSpec is a sink as created through the API.
+type CaptionSinkSpec struct {
+	Type     string `json:"type" enum:"file,webhook,websocket" doc:"Kind of destination"`
+	Language string `json:"language,omitempty" doc:"Only send captions of this language, all captioned languages if empty"`
+	Target   string `json:"target" doc:"File name in CAPTION_SINK_DIR, or URL of the webhook or WebSocket on a host in CAPTION_SINK_HOSTS"`
+	Secret   string `json:"secret,omitempty" doc:"HMAC secret of webhook requests, generated if empty"`
+}
+
+// CaptionSinkInfo is a sink of a bot. The secret is only returned when the
+// sink is created.
+type CaptionSinkInfo struct {
+	ID string `json:"id"`
+	CaptionSinkSpec
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type captionSinkEntry struct {
+	info CaptionSinkInfo
+	sink CaptionSink
+}
+
+// captionSinkTypes creates the sinks of each type. New sinks only have to be
+// added here.
+var captionSinkTypes = map[string]func(spec CaptionSinkSpec, config CaptionSinkConfig) (CaptionSink, error){
+	"file":      newFileSink,
+	"webhook":   newWebhookSink,
+	"websocket": newWebSocketSink,
+}
+
+func newCaptionSink(spec CaptionSinkSpec, config CaptionSinkConfig) (CaptionSink, error) {
+	create, ok := captionSinkTypes[spec.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink type: %s", spec.Type)
+	}
+	return create(spec, config)
+}
+
+// WriteCaption writes the caption to the pad.
+func (w *PadWriter) WriteCaption(caption Caption) error {
+	if caption.SegmentID != "" {
+		return w.WriteSegment(caption.SegmentID, caption.Text, caption.Final)
+	}
+	return w.SetText(caption.Text)
+}
+
+// Close does nothing, the pad belongs to the bot.
+func (w *PadWriter) Close() error {
+	return nil
+}
+
+// hlsSink writes the captions of one language to the HLS feed.
+type hlsSink struct {
+	feed *HLSFeed
+	lang string
+}
+
+// Sink returns the sink of the rendition of lang.
+func (f *HLSFeed) Sink(lang string) CaptionSink {
+	return &hlsSink{feed: f, lang: lang}
+}
+
+func (s *hlsSink) WriteCaption(caption Caption) error {
+	s.feed.Write(s.lang, caption.Text)
+	return nil
+}
+
+func (s *hlsSink) Close() error {
+	return nil
+}
+
+// fileSink appends every caption as a JSON line to a file.
+type fileSink struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+func newFileSink(spec CaptionSinkSpec, config CaptionSinkConfig) (CaptionSink, error) {
+	// Clients must not write anywhere else on the server
+	if !filepath.IsLocal(spec.Target) {
+		return nil, fmt.Errorf("file name must be relative and stay in the sink directory: %s", spec.Target)
+	}
+	path := filepath.Join(config.Dir, spec.Target)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) WriteCaption(caption Caption) error {
+	line, err := json.Marshal(caption)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Close()
+}
+
+// captionQueue hands captions to the goroutine of a sink without blocking the
+// transcript.
+type captionQueue struct {
+	captions chan Caption
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newCaptionQueue() *captionQueue {
+	return &captionQueue{
+		captions: make(chan Caption, captionQueueLength),
+		done:     make(chan struct{}),
+	}
+}
+
+func (q *captionQueue) push(caption Caption) error {
+	select {
+	case <-q.done:
+		return fmt.Errorf("sink is closed")
+	default:
+	}
+	select {
+	case q.captions <- caption:
+		return nil
+	default:
+		return fmt.Errorf("sink queue is full, caption dropped")
+	}
+}
+
+func (q *captionQueue) close() {
+	q.once.Do(func() { close(q.done) })
+}
+
+// webhookSink POSTs every caption as JSON. Requests are signed like the
+// lifecycle webhooks, with X-Webhook-Event set to "caption". Failed requests
+// are not retried, the caption would be outdated by then.
+type webhookSink struct {
+	*captionQueue
+	url    string
+	secret string
+	client *http.Client
+}
+
+func newWebhookSink(spec CaptionSinkSpec, config CaptionSinkConfig) (CaptionSink, error) {
+	u, err := url.Parse(spec.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("webhook target must be an http(s) URL: %s", spec.Target)
+	}
+	if !config.allowsHost(u) {
+		return nil, fmt.Errorf("webhook host is not in CAPTION_SINK_HOSTS: %s", u.Hostname())
+	}
+	s := &webhookSink{
+		captionQueue: newCaptionQueue(),
+		url:          spec.Target,
+		secret:       spec.Secret,
+		client: &http.Client{
+			Timeout: captionSinkTimeout,
+			// A redirect could lead to a host which is not allowed
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *webhookSink) WriteCaption(caption Caption) error {
+	return s.push(caption)
+}
+
+func (s *webhookSink) Close() error {
+	s.close()
+	return nil
+}
+
+func (s *webhookSink) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case caption := <-s.captions:
+			if err := s.send(caption); err != nil {
+				log.Printf("[WARN] Caption webhook %s failed: %v", s.url, err)
+			}
+		}
+	}
+}
+
+func (s *webhookSink) send(caption Caption) error {
+	body, err := json.Marshal(caption)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", "caption")
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(s.secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webSocketSink sends every caption as a JSON text message to a WebSocket
+// server. The connection is opened again when it breaks.
+type webSocketSink struct {
+	*captionQueue
+	url string
+}
+
+func newWebSocketSink(spec CaptionSinkSpec, config CaptionSinkConfig) (CaptionSink, error) {
+	u, err := url.Parse(spec.Target)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") {
+		return nil, fmt.Errorf("websocket target must be a ws(s) URL: %s", spec.Target)
+	}
+	if !config.allowsHost(u) {
+		return nil, fmt.Errorf("websocket host is not in CAPTION_SINK_HOSTS: %s", u.Hostname())
+	}
+	s := &webSocketSink{
+		captionQueue: newCaptionQueue(),
+		url:          spec.Target,
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *webSocketSink) WriteCaption(caption Caption) error {
+	return s.push(caption)
+}
+
+func (s *webSocketSink) Close() error {
+	s.close()
+	return nil
+}
+
+func (s *webSocketSink) run() {
+	dialer := websocket.Dialer{HandshakeTimeout: captionSinkTimeout}
+	backoff := time.Second
+	var conn *websocket.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case caption := <-s.captions:
+			if conn == nil {
+				var err error
+				conn, _, err = dialer.Dial(s.url, nil)
+				if err != nil {
+					// Captions are dropped while the server is unreachable
+					log.Printf("[WARN] Caption WebSocket %s unreachable: %v", s.url, err)
+					conn = nil
+					select {
+					case <-s.done:
+						return
+					case <-time.After(backoff):
+					}
+					backoff = min(backoff*2, webhookMaxBackoff)
+					continue
+				}
+				backoff = time.Second
+				// Reading handles pings and notices when the server closes
+				go func(conn *websocket.Conn) {
+					for {
+						if _, _, err := conn.NextReader(); err != nil {
+							return
+						}
+					}
+				}(conn)
+			}
+			conn.SetWriteDeadline(time.Now().Add(captionSinkTimeout))
+			if err := conn.WriteJSON(caption); err != nil {
+				log.Printf("[WARN] Caption WebSocket %s failed: %v", s.url, err)
+				conn.Close()
+				conn = nil
+			}
+		}
+	}
+}
+
+// generateSinkSecret returns a random secret for webhook sinks.
+func generateSinkSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCaptionSinkAllowsHost(t *testing.T) {
+	config := CaptionSinkConfig{Hosts: []string{"captions.example.com", "*.example.org"}}
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"https://captions.example.com/hook", true},
+		{"wss://CAPTIONS.example.com:8443/ws", true},
+		{"https://example.com/hook", false},
+		{"https://live.example.org/hook", true},
+		{"https://a.b.example.org/hook", true},
+		{"https://example.org/hook", false},
+		{"https://evilexample.org/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://captions.example.com.evil.net/hook", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			u, err := url.Parse(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := config.allowsHost(u); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (CaptionSinkConfig{}).allowsHost(&url.URL{Host: "captions.example.com"}) {
+		t.Error("empty list allowed a host")
+	}
+	if _, err := newWebhookSink(CaptionSinkSpec{Type: "webhook", Target: "http://10.0.0.1/"}, config); err == nil {
+		t.Error("webhook sink to a host which is not allowed was created")
+	}
+}
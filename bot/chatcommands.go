@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bigbluebutton-bot/bigbluebutton-bot/bbb"
+)
+
+// Chat ID of the public chat of every meeting
+const publicChatID = "MAIN-PUBLIC-GROUP-CHAT"
+
+// Role which allows everyone to use a command
+const chatRoleAnyone = "*"
+
+// ChatCommandConfig configures the commands the bot accepts in the public
+// chat.
+type ChatCommandConfig struct {
+	Enabled bool
+	// Commands start with this prefix, e.g. "!" for "!status"
+	Prefix string
+	// Command name -> roles which may use it (MODERATOR, VIEWER or *)
+	Permissions map[string][]string
+}
+
+type chatCommand struct {
+	usage   string
+	handler func(b *Bot, args []string) string
+}
+
+var chatCommands map[string]chatCommand
+
+// Filled in init, because the handlers refer to chatCommands themselves
+func init() {
+	chatCommands = map[string]chatCommand{
+		"captions": {"captions <lang> [lang...]", (*Bot).chatCaptions},
+		"stop":     {"stop <lang> [lang...]", (*Bot).chatStop},
+		"mode":     {"mode transcribe|translate", (*Bot).chatMode},
+		"status":   {"status", (*Bot).chatStatus},
+		"help":     {"help", (*Bot).chatHelp},
+	}
+}
+
+// onChatMessage runs the command in a public chat message and replies with
+// the result.
+func (b *Bot) onChatMessage(msg bbb.Message) {
+	config := b.chat_commands
+	if msg.ChatId != publicChatID || msg.Sender == b.client.InternalUserID {
+		return
+	}
+	// The subscription also delivers the chat history
+	if time.UnixMilli(msg.Timestamp).Before(b.JoinedAt) {
+		return
+	}
+
+	text := strings.TrimSpace(msg.Message)
+	if !strings.HasPrefix(text, config.Prefix) {
+		return
+	}
+	fields := strings.Fields(strings.TrimPrefix(text, config.Prefix))
+	if len(fields) == 0 {
+		return
+	}
+	name := strings.ToLower(fields[0])
+	command, ok := chatCommands[name]
+	if !ok {
+		return
+	}
+
+	var reply string
+	if !config.allowed(name, msg.SenderRole) {
+		reply = fmt.Sprintf("%s: you are not allowed to use %s%s", msg.SenderName, config.Prefix, name)
+	} else {
+		log.Printf("[INFO] Bot %s: chat command %q from %s", b.ID, text, msg.SenderName)
+		reply = command.handler(b, fields[1:])
+	}
+
+	if err := b.client.SendChatMsg(reply, publicChatID); err != nil {
+		log.Printf("[ERROR] Bot %s failed to reply in chat: %v", b.ID, err)
+	}
+}
+
+func (c ChatCommandConfig) allowed(command string, role string) bool {
+	for _, r := range c.Permissions[command] {
+		if r == chatRoleAnyone || strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bot) chatCaptions(args []string) string {
+	if len(args) == 0 {
+		return "Usage: " + b.chat_commands.Prefix + chatCommands["captions"].usage
+	}
+	if b.Task != TaskTranslate {
+		b.SetTask(TaskTranslate)
+	}
+
+	started := make([]string, 0)
+	failed := make([]string, 0)
+	for _, arg := range args {
+		lang, ok := bbbLanguage(arg)
+		if !ok || lang == b.SourceLanguage {
+			failed = append(failed, arg)
+			continue
+		}
+		if err := b.Translate(lang); err != nil {
+			log.Printf("[ERROR] Bot %s failed to translate to %s: %v", b.ID, lang, err)
+			failed = append(failed, lang)
+			continue
+		}
+		started = append(started, lang)
+	}
+	return chatResult("Started captions", started, failed)
+}
+
+func (b *Bot) chatStop(args []string) string {
+	if len(args) == 0 {
+		return "Usage: " + b.chat_commands.Prefix + chatCommands["stop"].usage
+	}
+
+	stopped := make([]string, 0)
+	failed := make([]string, 0)
+	for _, arg := range args {
+		lang, ok := bbbLanguage(arg)
+		if !ok {
+			failed = append(failed, arg)
+			continue
+		}
+		if err := b.StopTranslate(lang); err != nil {
+			failed = append(failed, arg)
+			continue
+		}
+		stopped = append(stopped, lang)
+	}
+	return chatResult("Stopped captions", stopped, failed)
+}
+
+func (b *Bot) chatMode(args []string) string {
+	if len(args) != 1 {
+		return "Usage: " + b.chat_commands.Prefix + chatCommands["mode"].usage
+	}
+	switch strings.ToLower(args[0]) {
+	case "transcribe":
+		b.SetTask(TaskTranscribe)
+	case "translate":
+		b.SetTask(TaskTranslate)
+	default:
+		return "Usage: " + b.chat_commands.Prefix + chatCommands["mode"].usage
+	}
+	return "Mode: " + b.Task.String()
+}
+
+func (b *Bot) chatStatus(_ []string) string {
+	b.updateStats()
+	status := fmt.Sprintf("Mode: %s | Languages: %s | In meeting for %s",
+		b.Task, strings.Join(b.GetAllActiveTranslations(), ", "), time.Since(b.JoinedAt).Round(time.Minute))
+	if b.TranscriptionServer == "" {
+		status += " | Not connected to a transcription server"
+	}
+	return status
+}
+
+func (b *Bot) chatHelp(_ []string) string {
+	names := make([]string, 0, len(chatCommands))
+	for name := range chatCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	usages := make([]string, 0, len(names))
+	for _, name := range names {
+		usages = append(usages, b.chat_commands.Prefix+chatCommands[name].usage)
+	}
+	return "Commands: " + strings.Join(usages, " | ")
+}
+
+func chatResult(action string, ok []string, failed []string) string {
+	result := action + ": " + strings.Join(ok, ", ")
+	if len(ok) == 0 {
+		result = action + ": none"
+	}
+	if len(failed) > 0 {
+		result += " (failed: " + strings.Join(failed, ", ") + ")"
+	}
+	return result
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	// "github.com/joho/godotenv"
 
@@ -15,6 +16,8 @@ import (
 type Settings struct {
 	Bot struct {
 		Limit int
+		// Default language spoken in meetings
+		SourceLanguage string
 	}
 	BBB struct {
 		API struct {
@@ -33,6 +36,7 @@ type Settings struct {
 		WebRTC struct {
 			WS string
 		}
+		Webhooks BBBWebhookConfig
 	}
 	ChangeSet struct {
 		External bool
@@ -44,11 +48,68 @@ type Settings struct {
 		PortTCP         int
 		Secret          string
 		HealthCheckPort int
+		TLS             struct {
+			Enabled    bool
+			CA         string
+			ServerName string
+			Cert       string
+			Key        string
+		}
+		KeyPins        []string
+		KnownHostsFile string
+		// All servers bots can be assigned to. Defaults to ExternalHost.
+		Pool []TranscriptionServerConfig
 	}
 	TranslationServer struct {
 		URL    string
 		Secret string
 	}
+	Audio struct {
+		SilenceSuppression bool
+		SilenceMaxPayload  int
+		SilenceHangover    time.Duration
+	}
+	Watchdog struct {
+		// How often all dependencies are checked
+		Interval time.Duration
+	}
+	RateLimit struct {
+		// Requests per minute and client for every operation, 0 disables it
+		PerMinute int
+		// Limits per operation ID which override PerMinute
+		Routes map[string]int
+		// Known X-API-Key values. Other clients are identified by their IP.
+		APIKeys []string
+		// Max bots and translated languages per client, 0 means unlimited
+		MaxBots      int
+		MaxLanguages int
+	}
+	// Default lifecycle policy of new bots
+	Lifecycle    LifecyclePolicy
+	ChatCommands ChatCommandConfig
+	Schedule     struct {
+		// JSON file the schedules are stored in, empty disables persistence
+		File string
+	}
+	Webhooks struct {
+		// JSON file the subscriptions are stored in, empty disables persistence
+		File string
+		// Hosts subscriptions may send events to
+		Hosts []string
+	}
+	// Size of the caption pads, older text is moved to the bot history
+	PadBudget PadBudget
+	// WebVTT subtitle playlists of the captions for HLS players
+	HLS HLSConfig
+	// Captions can also be sent to files, webhooks and WebSockets
+	CaptionSinks CaptionSinkConfig
+}
+
+// TranscriptionServerConfig is one entry of TRANSCRIPTION_SERVERS
+type TranscriptionServerConfig struct {
+	Host            string
+	PortTCP         int
+	HealthCheckPort int
 }
 
 // LoadSettings loads and validates the configuration settings from environment variables.
@@ -89,8 +150,53 @@ func LoadSettings() (*Settings, error) {
 		return numVal
 	}
 
+	// optString retrieves a string value for an optional key.
+	// If the value is missing, the default is returned.
+	optString := func(key string, def string) string {
+		val, ok := os.LookupEnv(key)
+		if !ok || val == "" {
+			return def
+		}
+		return val
+	}
+
+	// optInt retrieves an integer value for an optional key.
+	// If the value is not an integer, it records an error.
+	optInt := func(key string, def int) int {
+		strVal := optString(key, "")
+		if strVal == "" {
+			return def
+		}
+		numVal, err := strconv.Atoi(strVal)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s must be an integer (got: %q)", key, strVal))
+		}
+		return numVal
+	}
+
+	// optBool retrieves a boolean value for an optional key.
+	optBool := func(key string, def bool) bool {
+		strVal := optString(key, "")
+		if strVal == "" {
+			return def
+		}
+		return strVal == "true"
+	}
+
+	// optList retrieves a comma separated list for an optional key.
+	optList := func(key string) []string {
+		list := make([]string, 0)
+		for _, item := range strings.Split(optString(key, ""), ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+
 	// Assign all settings
 	cfg.Bot.Limit = mustInt("BOT_LIMIT")
+	cfg.Bot.SourceLanguage = optString("BOT_SOURCE_LANGUAGE", "en")
 
 	cfg.BBB.API.URL = mustString("BBB_API_URL")
 	cfg.BBB.API.Secret = mustString("BBB_API_SECRET")
@@ -112,9 +218,135 @@ func LoadSettings() (*Settings, error) {
 	cfg.TranscriptionServer.PortTCP = mustInt("TRANSCRIPTION_SERVER_PORT_TCP")
 	cfg.TranscriptionServer.Secret = mustString("TRANSCRIPTION_SERVER_SECRET")
 	cfg.TranscriptionServer.HealthCheckPort = mustInt("TRANSCRIPTION_SERVER_HEALTH_CHECK_PORT")
+	cfg.TranscriptionServer.TLS.Enabled = optBool("TRANSCRIPTION_SERVER_TLS", false)
+	cfg.TranscriptionServer.TLS.CA = optString("TRANSCRIPTION_SERVER_TLS_CA", "")
+	cfg.TranscriptionServer.TLS.ServerName = optString("TRANSCRIPTION_SERVER_TLS_SERVER_NAME", "")
+	cfg.TranscriptionServer.TLS.Cert = optString("TRANSCRIPTION_SERVER_TLS_CERT", "")
+	cfg.TranscriptionServer.TLS.Key = optString("TRANSCRIPTION_SERVER_TLS_KEY", "")
+	cfg.TranscriptionServer.KeyPins = optList("TRANSCRIPTION_SERVER_KEY_PINS")
+	cfg.TranscriptionServer.KnownHostsFile = optString("TRANSCRIPTION_SERVER_KNOWN_HOSTS", "")
+
+	// TRANSCRIPTION_SERVERS="host:tcp_port[:health_check_port],..."
+	for _, entry := range optList("TRANSCRIPTION_SERVERS") {
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			errs = append(errs, fmt.Sprintf("TRANSCRIPTION_SERVERS entry must be host:tcp_port[:health_check_port] (got: %q)", entry))
+			continue
+		}
+		server := TranscriptionServerConfig{
+			Host:            parts[0],
+			HealthCheckPort: cfg.TranscriptionServer.HealthCheckPort,
+		}
+		var err error
+		if server.PortTCP, err = strconv.Atoi(parts[1]); err != nil {
+			errs = append(errs, fmt.Sprintf("TRANSCRIPTION_SERVERS port must be an integer (got: %q)", entry))
+			continue
+		}
+		if len(parts) == 3 {
+			if server.HealthCheckPort, err = strconv.Atoi(parts[2]); err != nil {
+				errs = append(errs, fmt.Sprintf("TRANSCRIPTION_SERVERS health check port must be an integer (got: %q)", entry))
+				continue
+			}
+		}
+		cfg.TranscriptionServer.Pool = append(cfg.TranscriptionServer.Pool, server)
+	}
+	if len(cfg.TranscriptionServer.Pool) == 0 {
+		cfg.TranscriptionServer.Pool = []TranscriptionServerConfig{{
+			Host:            cfg.TranscriptionServer.ExternalHost,
+			PortTCP:         cfg.TranscriptionServer.PortTCP,
+			HealthCheckPort: cfg.TranscriptionServer.HealthCheckPort,
+		}}
+	}
 
 	cfg.TranslationServer.URL = mustString("TRANSLATION_SERVER_URL")
 
+	cfg.Audio.SilenceSuppression = optBool("AUDIO_SILENCE_SUPPRESSION", false)
+	cfg.Audio.SilenceMaxPayload = optInt("AUDIO_SILENCE_MAX_PAYLOAD", 10)
+	cfg.Audio.SilenceHangover = time.Duration(optInt("AUDIO_SILENCE_HANGOVER_MS", 1000)) * time.Millisecond
+
+	cfg.Watchdog.Interval = time.Duration(optInt("WATCHDOG_INTERVAL_SECONDS", 10)) * time.Second
+	if cfg.Watchdog.Interval <= 0 {
+		errs = append(errs, "WATCHDOG_INTERVAL_SECONDS must be positive")
+	}
+
+	cfg.RateLimit.PerMinute = optInt("RATE_LIMIT_PER_MINUTE", 0)
+	// Format: operation=limit,operation=limit e.g. bot-join=5,bot-translate-start=30
+	cfg.RateLimit.Routes = make(map[string]int)
+	for _, entry := range optList("RATE_LIMIT_ROUTES") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			errs = append(errs, fmt.Sprintf("RATE_LIMIT_ROUTES entry must be operation=limit (got: %q)", entry))
+			continue
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("RATE_LIMIT_ROUTES limit must be an integer (got: %q)", entry))
+			continue
+		}
+		cfg.RateLimit.Routes[strings.TrimSpace(parts[0])] = limit
+	}
+	cfg.RateLimit.APIKeys = optList("RATE_LIMIT_API_KEYS")
+	cfg.RateLimit.MaxBots = optInt("QUOTA_MAX_BOTS_PER_CLIENT", 0)
+	cfg.RateLimit.MaxLanguages = optInt("QUOTA_MAX_LANGUAGES_PER_CLIENT", 0)
+
+	cfg.BBB.Webhooks.CallbackURL = optString("BBB_WEBHOOK_CALLBACK_URL", "")
+	cfg.BBB.Webhooks.JoinPattern = optString("BBB_WEBHOOK_JOIN_PATTERN", "")
+	cfg.BBB.Webhooks.JoinTask = optString("BBB_WEBHOOK_JOIN_TASK", "transcribe")
+	cfg.BBB.Webhooks.JoinLanguages = optList("BBB_WEBHOOK_JOIN_LANGUAGES")
+	if task := cfg.BBB.Webhooks.JoinTask; task != "transcribe" && task != "translate" {
+		errs = append(errs, fmt.Sprintf("BBB_WEBHOOK_JOIN_TASK must be transcribe or translate (got: %q)", task))
+	}
+
+	cfg.Lifecycle.IdleTimeoutMinutes = optInt("BOT_IDLE_TIMEOUT_MINUTES", 0)
+	cfg.Lifecycle.LeaveWhenAlone = optBool("BOT_LEAVE_WHEN_ALONE", false)
+	cfg.Lifecycle.AloneGraceMinutes = optInt("BOT_ALONE_GRACE_MINUTES", 5)
+	cfg.Lifecycle.MaxDurationMinutes = optInt("BOT_MAX_DURATION_MINUTES", 0)
+
+	cfg.ChatCommands.Enabled = optBool("CHAT_COMMANDS_ENABLED", false)
+	cfg.ChatCommands.Prefix = optString("CHAT_COMMAND_PREFIX", "!")
+	// Format: command=ROLE|ROLE,command=ROLE e.g. captions=MODERATOR,status=*
+	cfg.ChatCommands.Permissions = map[string][]string{
+		"captions": {"MODERATOR"},
+		"stop":     {"MODERATOR"},
+		"mode":     {"MODERATOR"},
+		"status":   {"*"},
+		"help":     {"*"},
+	}
+	for _, entry := range optList("CHAT_COMMAND_PERMISSIONS") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			errs = append(errs, fmt.Sprintf("CHAT_COMMAND_PERMISSIONS entry must be command=ROLE|ROLE (got: %q)", entry))
+			continue
+		}
+		cfg.ChatCommands.Permissions[strings.TrimSpace(parts[0])] = strings.Split(strings.TrimSpace(parts[1]), "|")
+	}
+
+	cfg.Schedule.File = optString("SCHEDULE_FILE", "schedules.json")
+	cfg.Webhooks.File = optString("WEBHOOK_FILE", "webhooks.json")
+	cfg.Webhooks.Hosts = optList("WEBHOOK_HOSTS")
+
+	cfg.PadBudget.MaxChars = optInt("PAD_MAX_CHARS", 5000)
+	cfg.PadBudget.KeepChars = optInt("PAD_KEEP_CHARS", 1000)
+	if cfg.PadBudget.MaxChars > 0 && cfg.PadBudget.KeepChars >= cfg.PadBudget.MaxChars {
+		errs = append(errs, "PAD_KEEP_CHARS must be smaller than PAD_MAX_CHARS")
+	}
+
+	cfg.HLS.SegmentDuration = time.Duration(optInt("HLS_SEGMENT_SECONDS", 6)) * time.Second
+	cfg.HLS.Window = optInt("HLS_WINDOW_SEGMENTS", 10)
+	if cfg.HLS.SegmentDuration <= 0 {
+		errs = append(errs, "HLS_SEGMENT_SECONDS must be positive")
+	}
+	// Players need at least three segments in a live playlist
+	if cfg.HLS.Window < 3 {
+		errs = append(errs, "HLS_WINDOW_SEGMENTS must be at least 3")
+	}
+
+	cfg.CaptionSinks.Dir = optString("CAPTION_SINK_DIR", "captions")
+	cfg.CaptionSinks.Hosts = optList("CAPTION_SINK_HOSTS")
+	cfg.CaptionSinks.MaxPerBot = optInt("CAPTION_SINK_MAX_PER_BOT", 5)
+	if cfg.CaptionSinks.MaxPerBot < 0 {
+		errs = append(errs, "CAPTION_SINK_MAX_PER_BOT must not be negative")
+	}
 
 	// If any errors were recorded, return them as a single error
 	if len(errs) > 0 {
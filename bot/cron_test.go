@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field   string
+		min     int
+		max     int
+		want    []int
+		wantErr bool
+	}{
+		{field: "*", min: 0, max: 6, want: []int{0, 1, 2, 3, 4, 5, 6}},
+		{field: "5", min: 0, max: 59, want: []int{5}},
+		{field: "1,3", min: 0, max: 6, want: []int{1, 3}},
+		{field: "1-3", min: 0, max: 6, want: []int{1, 2, 3}},
+		{field: "*/15", min: 0, max: 59, want: []int{0, 15, 30, 45}},
+		{field: "8-18/4", min: 0, max: 23, want: []int{8, 12, 16}},
+		{field: "5/15", min: 0, max: 59, want: []int{5, 20, 35, 50}},
+		{field: "*/5", min: 1, max: 12, want: []int{1, 6, 11}},
+		{field: "1-2,10/5", min: 0, max: 23, want: []int{1, 2, 10, 15, 20}},
+		{field: "60", min: 0, max: 59, wantErr: true},
+		{field: "5-1", min: 0, max: 59, wantErr: true},
+		{field: "*/0", min: 0, max: 59, wantErr: true},
+		{field: "a", min: 0, max: 59, wantErr: true},
+		{field: "0", min: 1, max: 31, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			bits, err := parseCronField(tt.field, tt.min, tt.max)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %b, want an error", bits)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want uint64
+			for _, v := range tt.want {
+				want |= 1 << uint(v)
+			}
+			if bits != want {
+				t.Errorf("got %b, want %b", bits, want)
+			}
+		})
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// Monday, 2024-01-15
+	monday := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		time time.Time
+		want bool
+	}{
+		{"0 10 * * 1-5", monday, true},
+		{"0 10 * * 1-5", monday.AddDate(0, 0, 5), false},
+		{"0 10 * * 0", monday.AddDate(0, 0, 6), true},
+		{"0 10 * * 7", monday.AddDate(0, 0, 6), true},
+		{"*/30 * * * *", monday.Add(30 * time.Minute), true},
+		{"*/30 * * * *", monday.Add(31 * time.Minute), false},
+		{"5/15 * * * *", monday.Add(50 * time.Minute), true},
+		{"5/15 * * * *", monday.Add(45 * time.Minute), false},
+		// Both days restricted: either matches
+		{"0 10 1 * 1", monday, true},
+		{"0 10 15 * 5", monday, true},
+		{"0 10 1 * 5", monday, false},
+		// A stepped * does not restrict the day, only the day of week counts
+		{"0 10 */2 * 1", monday, true},
+		{"0 10 */2 * 5", monday, false},
+		{"0 10 * 2 *", monday, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.time.Format(time.RFC3339), func(t *testing.T) {
+			spec, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := spec.Matches(tt.time); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronLatest(t *testing.T) {
+	spec, err := ParseCron("0 10 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		now    time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{"at the start", start, start, true},
+		{"inside the window", start.Add(59*time.Minute + 30*time.Second), start, true},
+		{"after the window", start.Add(time.Hour), time.Time{}, false},
+		{"before the start", start.Add(-time.Minute), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := spec.Latest(tt.now, time.Hour)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("got %v %v, want %v %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
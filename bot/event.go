@@ -8,6 +8,12 @@ import (
 type Event struct {
 	eventLock     sync.Mutex
 	eventHandlers []func(message string)
+
+	// Set by NewOrderedEvent. Messages wait in queue until the handlers are
+	// done with the previous one.
+	ordered  bool
+	queue    []string
+	draining bool
 }
 
 func NewEvent() *Event {
@@ -17,14 +23,53 @@ func NewEvent() *Event {
 	}
 }
 
+// NewOrderedEvent creates an event whose handlers get the messages one after
+// another in the order they were emitted, instead of each in its own
+// goroutine. Emit still never blocks.
+func NewOrderedEvent() *Event {
+	e := NewEvent()
+	e.ordered = true
+	return e
+}
+
 func (e *Event) Emit(message string) {
 	e.eventLock.Lock()
 	defer e.eventLock.Unlock()
+	if e.ordered {
+		e.queue = append(e.queue, message)
+		if !e.draining {
+			e.draining = true
+			go e.drain()
+		}
+		return
+	}
 	for _, handler := range e.eventHandlers {
 		go handler(message)
 	}
 }
 
+// drain calls the handlers for every queued message until the queue is
+// empty. Handlers run without the lock, so they can add and remove handlers.
+func (e *Event) drain() {
+	for {
+		e.eventLock.Lock()
+		if len(e.queue) == 0 {
+			e.queue = nil
+			e.draining = false
+			e.eventLock.Unlock()
+			return
+		}
+		message := e.queue[0]
+		e.queue = e.queue[1:]
+		handlers := append([]func(message string){}, e.eventHandlers...)
+		e.eventLock.Unlock()
+
+		for _, handler := range handlers {
+			handler(message)
+		}
+	}
+}
+
 func (e *Event) Add(handler func(message string)) {
 	e.eventLock.Lock()
 	defer e.eventLock.Unlock()
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestOrderedEventKeepsOrder(t *testing.T) {
+	e := NewOrderedEvent()
+	got := make(chan string, 100)
+	e.Add(func(message string) {
+		// Later messages would overtake slow ones if each ran on its own
+		time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+		got <- message
+	})
+
+	for i := 0; i < 100; i++ {
+		e.Emit(strconv.Itoa(i))
+	}
+	for i := 0; i < 100; i++ {
+		select {
+		case message := <-got:
+			if message != strconv.Itoa(i) {
+				t.Fatalf("message %d is %s", i, message)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d was not handled", i)
+		}
+	}
+}
+
+func TestOrderedEventHandlerCanRemoveItself(t *testing.T) {
+	e := NewOrderedEvent()
+	got := make(chan string, 2)
+	var handler func(message string)
+	handler = func(message string) {
+		e.Remove(handler)
+		got <- message
+	}
+	e.Add(handler)
+
+	e.Emit("first")
+	e.Emit("second")
+	if message := <-got; message != "first" {
+		t.Fatalf("got %s, want first", message)
+	}
+	select {
+	case message := <-got:
+		t.Errorf("removed handler got %s", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
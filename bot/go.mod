@@ -6,12 +6,16 @@ toolchain go1.24.2
 
 require (
 	github.com/bigbluebutton-bot/bigbluebutton-bot v0.1.7
+	github.com/bigbluebutton-bot/golang-socketio v0.1.0
 	github.com/danielgtaylor/huma/v2 v2.32.0
 	github.com/go-chi/chi/v5 v5.2.2
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/pion/rtp v1.8.18
 	github.com/pion/webrtc/v3 v3.3.5
 	github.com/pion/webrtc/v4 v4.1.0
+	github.com/spf13/cobra v1.8.1
+	golang.org/x/crypto v0.37.0
 )
 
 require (
@@ -22,7 +26,6 @@ require (
 	github.com/benpate/convert v0.13.5 // indirect
 	github.com/benpate/derp v0.22.2 // indirect
 	github.com/benpate/null v0.6.4 // indirect
-	github.com/bigbluebutton-bot/golang-socketio v0.1.0 // indirect
 	github.com/cloudflare/circl v1.6.1 // indirect
 	github.com/cyphar/filepath-securejoin v0.4.1 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -32,7 +35,6 @@ require (
 	github.com/go-git/go-git/v5 v5.16.0 // indirect
 	github.com/golang/groupcache v0.0.0-20241129210726-2c02b8208cf8 // indirect
 	github.com/gopackage/ddp v0.0.6 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/jbenet/go-context v0.0.0-20150711004518-d14ea06fba99 // indirect
 	github.com/kevinburke/ssh_config v1.2.0 // indirect
@@ -56,12 +58,10 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/sergi/go-diff v1.3.2-0.20230802210424-5b0b94c5c0d3 // indirect
 	github.com/skeema/knownhosts v1.3.1 // indirect
-	github.com/spf13/cobra v1.8.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/wlynxg/anet v0.0.5 // indirect
 	github.com/xanzy/ssh-agent v0.3.3 // indirect
-	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bigbluebutton-bot/bigbluebutton-bot/pad"
+)
+
+// Timeout for a single dependency check
+const dependencyCheckTimeout = 5 * time.Second
+
+// DependencyStatus is the result of one dependency check.
+type DependencyStatus struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Required  bool      `json:"required"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// dependency is an external service the bot needs.
+type dependency struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) error
+}
+
+// dependencies returns the checks for all external services.
+func dependencies() []dependency {
+	return []dependency{
+		{name: "bbb", required: true, check: checkBBB},
+		{name: "transcription", required: true, check: checkTranscription},
+		{name: "translation", required: false, check: checkTranslation},
+		{name: "changeset", required: true, check: checkChangeset},
+	}
+}
+
+// checkDependencies runs all checks in parallel.
+func checkDependencies(ctx context.Context, deps []dependency) []DependencyStatus {
+	statuses := make([]DependencyStatus, len(deps))
+
+	var wg sync.WaitGroup
+	for i, dep := range deps {
+		wg.Add(1)
+		go func(i int, dep dependency) {
+			defer wg.Done()
+			statuses[i] = runCheck(ctx, dep)
+		}(i, dep)
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+func runCheck(ctx context.Context, dep dependency) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, dependencyCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- dep.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", dependencyCheckTimeout)
+	}
+
+	status := DependencyStatus{
+		Name:      dep.name,
+		Healthy:   err == nil,
+		Required:  dep.required,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// checkBBB makes a checksum signed API call.
+func checkBBB(_ context.Context) error {
+	_, err := bbb_api.GetMeetings()
+	return err
+}
+
+// checkTranscription requires at least one healthy transcription server.
+func checkTranscription(_ context.Context) error {
+	if transcription_pool.CheckHealth() == 0 {
+		return fmt.Errorf("no healthy transcription server")
+	}
+	return nil
+}
+
+// checkTranslation asks LibreTranslate for its languages.
+func checkTranslation(ctx context.Context) error {
+	url := strings.TrimSuffix(conf.TranslationServer.URL, "/translate") + "/languages"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkChangeset generates a changeset on the external changeset service. A
+// local changeset server is started per pad, so there is nothing to check.
+func checkChangeset(_ context.Context) error {
+	if !conf.ChangeSet.External {
+		return nil
+	}
+	client := pad.NewChangesetClient(conf.ChangeSet.Host, strconv.Itoa(conf.ChangeSet.Port))
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	_, err := client.GenerateChangeset("\n", "ping\n", "|1+1")
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// HistoryEntry is caption text which was trimmed from a pad.
+type HistoryEntry struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+// TranscriptHistory keeps the caption text of a bot which is no longer
+// visible in the pads, per language.
+type TranscriptHistory struct {
+	lock    sync.Mutex
+	entries map[string][]HistoryEntry
+}
+
+func NewTranscriptHistory() *TranscriptHistory {
+	return &TranscriptHistory{
+		entries: make(map[string][]HistoryEntry),
+	}
+}
+
+func (h *TranscriptHistory) Add(lang string, text string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.entries[lang] = append(h.entries[lang], HistoryEntry{Time: time.Now(), Text: text})
+}
+
+// Entries returns the history of lang, or of all languages if lang is empty.
+func (h *TranscriptHistory) Entries(lang string) map[string][]HistoryEntry {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	entries := make(map[string][]HistoryEntry)
+	for l, e := range h.entries {
+		if lang == "" || l == lang {
+			entries[l] = append([]HistoryEntry(nil), e...)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A cue stays visible this long after its last update
+const hlsCueHold = 4 * time.Second
+
+// Longer captions are cut to their end, players can't scroll
+const hlsCueMaxChars = 120
+
+// HLSConfig configures the WebVTT subtitle playlists of the bots.
+type HLSConfig struct {
+	SegmentDuration time.Duration
+	// Number of segments in the live playlist
+	Window int
+}
+
+// HLSRendition is a subtitle rendition of a bot.
+type HLSRendition struct {
+	Language string `json:"language"`
+	// Path of the media playlist
+	URI string `json:"uri"`
+	// EXT-X-MEDIA tag for the master playlist of the livestream
+	Media string `json:"media"`
+	// Time the cue times of the segments are relative to
+	Start time.Time `json:"start"`
+}
+
+func NewHLSRendition(botID string, lang string, start time.Time) HLSRendition {
+	uri := "/api/v1/bot/" + botID + "/hls/" + lang + "/playlist.m3u8"
+	return HLSRendition{
+		Language: lang,
+		URI:      uri,
+		Start:    start,
+		Media:    fmt.Sprintf(`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME=%q,LANGUAGE=%q,AUTOSELECT=YES,URI=%q`, lang, lang, uri),
+	}
+}
+
+type hlsCue struct {
+	start time.Time
+	// Zero while the cue is still shown
+	end  time.Time
+	text string
+}
+
+type hlsTrack struct {
+	cues       []*hlsCue
+	lastUpdate time.Time
+}
+
+// HLSFeed keeps the captions of a bot as rolling WebVTT subtitle renditions,
+// one per language. Segments are not cut in the background but rendered from
+// the cues on request. Segment i covers the time from epoch+i*duration until
+// the next segment starts, so all languages share the same segments.
+//
+// Cue times are relative to the epoch. By default the segments map the epoch
+// to MPEG-TS timestamp 0, players then have to line the captions up with
+// EXT-X-PROGRAM-DATE-TIME. If the timestamp of the video at the epoch is
+// known, it can be passed as mpegts instead.
+type HLSFeed struct {
+	lock   sync.Mutex
+	config HLSConfig
+	epoch  time.Time
+	tracks map[string]*hlsTrack
+}
+
+func NewHLSFeed(config HLSConfig) *HLSFeed {
+	return &HLSFeed{
+		config: config,
+		epoch:  time.Now(),
+		tracks: make(map[string]*hlsTrack),
+	}
+}
+
+// Write shows text in the rendition of lang from now on. An empty text clears
+// the caption.
+func (f *HLSFeed) Write(lang string, text string) {
+	f.write(lang, text, time.Now())
+}
+
+func (f *HLSFeed) write(lang string, text string, now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	text = cueText(text)
+	track, ok := f.tracks[lang]
+	if !ok {
+		track = &hlsTrack{}
+		f.tracks[lang] = track
+	}
+
+	if n := len(track.cues); n > 0 && track.cues[n-1].end.IsZero() {
+		open := track.cues[n-1]
+		expires := track.lastUpdate.Add(hlsCueHold)
+		switch {
+		case expires.Before(now):
+			open.end = expires
+		case open.text == text:
+			track.lastUpdate = now
+			return
+		default:
+			open.end = now
+		}
+	}
+	if text != "" {
+		track.cues = append(track.cues, &hlsCue{start: now, text: text})
+	}
+	track.lastUpdate = now
+
+	// Drop cues which ended before the oldest segment of the playlist
+	first, _ := f.segments(now)
+	windowStart := f.segmentStart(first)
+	kept := track.cues[:0]
+	for _, cue := range track.cues {
+		if cue.end.IsZero() || cue.end.After(windowStart) {
+			kept = append(kept, cue)
+		}
+	}
+	track.cues = kept
+}
+
+// Start returns the time the cue times are relative to.
+func (f *HLSFeed) Start() time.Time {
+	return f.epoch
+}
+
+// Languages returns the languages captions were written in.
+func (f *HLSFeed) Languages() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	langs := make([]string, 0, len(f.tracks))
+	for lang := range f.tracks {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Playlist returns the live media playlist of lang. It lists the finished
+// segments of the window as "<sequence>.vtt", with mpegts passed on to them
+// if it is set.
+func (f *HLSFeed) Playlist(lang string, mpegts int64) (string, bool) {
+	return f.playlist(lang, mpegts, time.Now())
+}
+
+func (f *HLSFeed) playlist(lang string, mpegts int64, now time.Time) (string, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.tracks[lang]; !ok {
+		return "", false
+	}
+	first, last := f.segments(now)
+	duration := f.config.SegmentDuration.Seconds()
+	query := ""
+	if mpegts > 0 {
+		query = "?mpegts=" + strconv.FormatInt(mpegts, 10)
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(duration)))
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", first)
+	for seq := first; seq <= last; seq++ {
+		fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", f.segmentStart(seq).UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", duration)
+		fmt.Fprintf(&b, "%d.vtt%s\n", seq, query)
+	}
+	return b.String(), true
+}
+
+// Segment returns the WebVTT segment seq of lang. Only the finished segments
+// of the window exist. Cue times are relative to the start of the feed, which
+// is mapped to the MPEG-TS timestamp mpegts.
+func (f *HLSFeed) Segment(lang string, seq int, mpegts int64) (string, bool) {
+	return f.segment(lang, seq, mpegts, time.Now())
+}
+
+func (f *HLSFeed) segment(lang string, seq int, mpegts int64, now time.Time) (string, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	track, ok := f.tracks[lang]
+	if !ok {
+		return "", false
+	}
+	first, last := f.segments(now)
+	if seq < first || seq > last {
+		return "", false
+	}
+	start := f.segmentStart(seq)
+	end := f.segmentStart(seq + 1)
+
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	fmt.Fprintf(&b, "X-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000\n", mpegts)
+	for _, cue := range track.cues {
+		cueEnd := cue.end
+		if cueEnd.IsZero() {
+			cueEnd = track.lastUpdate.Add(hlsCueHold)
+		}
+		// Cues spanning several segments are repeated in each of them
+		if !cue.start.Before(end) || !cueEnd.After(start) {
+			continue
+		}
+		cueStart := cue.start
+		if cueStart.Before(start) {
+			cueStart = start
+		}
+		if cueEnd.After(end) {
+			cueEnd = end
+		}
+		fmt.Fprintf(&b, "\n%s --> %s\n%s\n", vttTime(cueStart.Sub(f.epoch)), vttTime(cueEnd.Sub(f.epoch)), cue.text)
+	}
+	return b.String(), true
+}
+
+// segments returns the sequence numbers of the finished segments in the
+// window. last is smaller than first if no segment has finished yet.
+func (f *HLSFeed) segments(now time.Time) (first int, last int) {
+	last = int(now.Sub(f.epoch)/f.config.SegmentDuration) - 1
+	first = last - f.config.Window + 1
+	if first < 0 {
+		first = 0
+	}
+	return first, last
+}
+
+func (f *HLSFeed) segmentStart(seq int) time.Time {
+	return f.epoch.Add(time.Duration(seq) * f.config.SegmentDuration)
+}
+
+// cueText puts text on one line, keeps its end if it is too long and escapes
+// it for WebVTT.
+func cueText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > hlsCueMaxChars {
+		text = string(runes[len(runes)-hlsCueMaxChars:])
+		if i := strings.IndexByte(text, ' '); i >= 0 {
+			text = text[i+1:]
+		}
+	}
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// vttTime formats d as a WebVTT timestamp, e.g. 00:01:02.500
+func vttTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
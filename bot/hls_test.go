@@ -0,0 +1,205 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var hlsTestEpoch = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestHLSFeed(window int) *HLSFeed {
+	f := NewHLSFeed(HLSConfig{SegmentDuration: 6 * time.Second, Window: window})
+	f.epoch = hlsTestEpoch
+	return f
+}
+
+// at returns the time sec seconds after the epoch of the test feeds.
+func at(sec int) time.Time {
+	return hlsTestEpoch.Add(time.Duration(sec) * time.Second)
+}
+
+// cues returns the cue lines of a segment without its header.
+func cues(t *testing.T, f *HLSFeed, lang string, seq int, now time.Time) string {
+	t.Helper()
+	segment, ok := f.segment(lang, seq, 0, now)
+	if !ok {
+		t.Fatalf("segment %d not found", seq)
+	}
+	header := "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:0,LOCAL:00:00:00.000\n"
+	if !strings.HasPrefix(segment, header) {
+		t.Fatalf("segment %d has no header: %q", seq, segment)
+	}
+	return strings.TrimPrefix(segment, header)
+}
+
+func TestVTTTime(t *testing.T) {
+	for _, tt := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "00:00:00.000"},
+		{1500 * time.Millisecond, "00:00:01.500"},
+		{62*time.Second + 500*time.Millisecond, "00:01:02.500"},
+		{time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, "01:02:03.004"},
+		{25 * time.Hour, "25:00:00.000"},
+	} {
+		if got := vttTime(tt.d); got != tt.want {
+			t.Errorf("vttTime(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestCueText(t *testing.T) {
+	long := strings.Repeat("word ", 30) + "end"
+	for _, tt := range []struct {
+		name string
+		text string
+		want string
+	}{
+		{"one line", " Hello\n  World ", "Hello World"},
+		{"escaped", "a < b & c > d", "a &lt; b &amp; c &gt; d"},
+		{"long text keeps its end", long, strings.Repeat("word ", 23) + "end"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cueText(tt.text); got != tt.want {
+				t.Errorf("cueText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHLSCueHoldAndSpan(t *testing.T) {
+	f := newTestHLSFeed(3)
+	f.write("en", "Hello", at(1))
+	// The same text only keeps the cue open
+	f.write("en", "Hello", at(2))
+	f.write("en", "Hello World", at(3))
+
+	// The open cue is shown until lastUpdate+hlsCueHold and clipped to the
+	// segments it spans
+	now := at(12)
+	if got, want := cues(t, f, "en", 0, now),
+		"\n00:00:01.000 --> 00:00:03.000\nHello\n"+
+			"\n00:00:03.000 --> 00:00:06.000\nHello World\n"; got != want {
+		t.Errorf("segment 0:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := cues(t, f, "en", 1, now),
+		"\n00:00:06.000 --> 00:00:07.000\nHello World\n"; got != want {
+		t.Errorf("segment 1:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHLSExpiredCue(t *testing.T) {
+	f := newTestHLSFeed(3)
+	f.write("en", "First", at(1))
+	// First expired at 5s, long before the next caption
+	f.write("en", "Second", at(10))
+	// Clearing the caption closes the cue right away
+	f.write("en", "", at(11))
+
+	now := at(18)
+	if got, want := cues(t, f, "en", 0, now),
+		"\n00:00:01.000 --> 00:00:05.000\nFirst\n"; got != want {
+		t.Errorf("segment 0:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := cues(t, f, "en", 1, now),
+		"\n00:00:10.000 --> 00:00:11.000\nSecond\n"; got != want {
+		t.Errorf("segment 1:\n%s\nwant:\n%s", got, want)
+	}
+	if got := cues(t, f, "en", 2, now); got != "" {
+		t.Errorf("segment 2 = %q, want no cues", got)
+	}
+}
+
+func TestHLSFinishedSegmentsDoNotChange(t *testing.T) {
+	f := newTestHLSFeed(5)
+	f.write("en", "Hello", at(1))
+	before := cues(t, f, "en", 0, at(7))
+
+	f.write("en", "Hello", at(8))
+	f.write("en", "Hello again", at(9))
+	f.write("en", "", at(10))
+	f.write("en", "Later", at(20))
+
+	for _, now := range []time.Time{at(9), at(13), at(30)} {
+		if got := cues(t, f, "en", 0, now); got != before {
+			t.Errorf("segment 0 at %v changed from %q to %q", now.Sub(hlsTestEpoch), before, got)
+		}
+	}
+}
+
+func TestHLSPruning(t *testing.T) {
+	f := newTestHLSFeed(2)
+	f.write("en", "Old", at(1))
+	f.write("en", "", at(2))
+	f.write("en", "Open", at(3))
+	// The window is now segments 1 and 2, Old ended before it
+	f.write("en", "New", at(20))
+
+	var texts []string
+	for _, cue := range f.tracks["en"].cues {
+		texts = append(texts, cue.text)
+	}
+	if got := strings.Join(texts, ","); got != "Open,New" {
+		t.Errorf("cues = %s, want Open,New", got)
+	}
+	if _, ok := f.segment("en", 0, 0, at(20)); ok {
+		t.Error("segment 0 is still served after leaving the window")
+	}
+}
+
+func TestHLSSegmentWindow(t *testing.T) {
+	f := newTestHLSFeed(2)
+	f.write("en", "Hello", at(1))
+
+	for _, tt := range []struct {
+		seq  int
+		now  time.Time
+		want bool
+	}{
+		{0, at(5), false},
+		{0, at(6), true},
+		{1, at(6), false},
+		{1, at(12), true},
+		{0, at(18), false},
+		{-1, at(18), false},
+	} {
+		if _, ok := f.segment("en", tt.seq, 0, tt.now); ok != tt.want {
+			t.Errorf("segment %d at %v: ok = %v, want %v", tt.seq, tt.now.Sub(hlsTestEpoch), ok, tt.want)
+		}
+	}
+	if _, ok := f.segment("de", 0, 0, at(12)); ok {
+		t.Error("segment of an unknown language")
+	}
+
+	segment, _ := f.segment("en", 0, 900000, at(12))
+	if !strings.HasPrefix(segment, "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n") {
+		t.Errorf("segment header does not map to mpegts: %q", segment)
+	}
+}
+
+func TestHLSPlaylist(t *testing.T) {
+	f := newTestHLSFeed(2)
+	if _, ok := f.playlist("en", 0, at(1)); ok {
+		t.Error("playlist of a language without captions")
+	}
+	f.write("en", "Hello", at(1))
+
+	got, ok := f.playlist("en", 0, at(5))
+	if !ok {
+		t.Fatal("playlist not found")
+	}
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:0\n"
+	if got != want {
+		t.Errorf("playlist before the first segment:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, _ = f.playlist("en", 900000, at(20))
+	want = "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:1\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2026-01-02T03:04:11.000Z\n#EXTINF:6.000,\n1.vtt?mpegts=900000\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2026-01-02T03:04:17.000Z\n#EXTINF:6.000,\n2.vtt?mpegts=900000\n"
+	if got != want {
+		t.Errorf("playlist:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	// Number of packets the jitter buffer holds back while waiting for a missing
+	// sequence number. With 20ms Opus frames this is ~200ms of extra latency.
+	defaultJitterDepth = 10
+
+	// Default Opus frame size in samples (20ms at 48kHz). Used until the real
+	// frame size has been learned from consecutive packets.
+	defaultOpusFrameSamples = 960
+
+	// Upper bound for a plausible Opus frame (120ms at 48kHz).
+	maxOpusFrameSamples = 5760
+)
+
+// Opus packet with TOC 0xF8 (CELT fullband, 20ms, mono, one frame) and an empty
+// body. Decoders treat it as silence, which keeps the Ogg granule positions
+// continuous for packets that never arrived.
+var opusSilenceFrame = []byte{0xf8, 0xff, 0xfe}
+
+// JitterStats holds the counters of a JitterBuffer.
+type JitterStats struct {
+	Received    uint64 `json:"received"`
+	Lost        uint64 `json:"lost"`
+	Reordered   uint64 `json:"reordered"`
+	Duplicates  uint64 `json:"duplicates"`
+	Late        uint64 `json:"late"`
+	Concealed   uint64 `json:"concealed"`
+	Resyncs     uint64 `json:"resyncs"`
+	SSRCChanges uint64 `json:"ssrc_changes"`
+}
+
+// JitterBuffer reorders RTP packets by sequence number before they are handed
+// to the Ogg muxer. Gaps are filled with Opus silence frames once the buffer is
+// full, and timestamps are rebased on SSRC changes so the granule position
+// written by the oggwriter never jumps backwards.
+type JitterBuffer struct {
+	lock    sync.Mutex
+	depth   int
+	packets map[uint16]*rtp.Packet
+
+	started    bool
+	ssrc       uint32
+	nextSeq    uint16
+	highestSeq uint16
+
+	// Timestamp bookkeeping in the output timebase
+	emitted      bool
+	lastSeq      uint16
+	lastInputTS  uint32
+	lastOutputTS uint32
+	tsOffset     uint32
+	rebase       bool
+	frameSamples uint32
+
+	stats JitterStats
+}
+
+func NewJitterBuffer(depth int) *JitterBuffer {
+	if depth < 1 {
+		depth = defaultJitterDepth
+	}
+	return &JitterBuffer{
+		depth:        depth,
+		packets:      make(map[uint16]*rtp.Packet),
+		frameSamples: defaultOpusFrameSamples,
+	}
+}
+
+// Push adds a packet to the buffer and returns all packets which are ready to
+// be written, in sequence order. The returned slice may be empty.
+func (jb *JitterBuffer) Push(packet *rtp.Packet) []*rtp.Packet {
+	jb.lock.Lock()
+	defer jb.lock.Unlock()
+
+	jb.stats.Received++
+
+	out := make([]*rtp.Packet, 0, 1)
+
+	if !jb.started {
+		jb.started = true
+		jb.ssrc = packet.SSRC
+		jb.nextSeq = packet.SequenceNumber
+		jb.highestSeq = packet.SequenceNumber
+	}
+
+	// A new SSRC starts a new sequence and timestamp space. Drain what is left
+	// of the old stream and continue the output timeline right after it.
+	if packet.SSRC != jb.ssrc {
+		out = jb.drain(out)
+		jb.stats.SSRCChanges++
+		jb.ssrc = packet.SSRC
+		jb.nextSeq = packet.SequenceNumber
+		jb.highestSeq = packet.SequenceNumber
+		jb.rebase = jb.emitted
+	}
+
+	diff := int16(packet.SequenceNumber - jb.nextSeq)
+	if diff < 0 {
+		// Already played out or concealed
+		jb.stats.Late++
+		return out
+	}
+	if _, ok := jb.packets[packet.SequenceNumber]; ok {
+		jb.stats.Duplicates++
+		return out
+	}
+
+	if int16(packet.SequenceNumber-jb.highestSeq) < 0 {
+		jb.stats.Reordered++
+	} else {
+		jb.highestSeq = packet.SequenceNumber
+	}
+
+	jb.packets[packet.SequenceNumber] = packet
+
+	out = jb.pop(out)
+	for len(jb.packets) > jb.depth {
+		out = jb.skipGap(out)
+		out = jb.pop(out)
+	}
+
+	return out
+}
+
+// Flush returns all buffered packets in order, concealing any gaps between
+// them. Called when the track ends.
+func (jb *JitterBuffer) Flush() []*rtp.Packet {
+	jb.lock.Lock()
+	defer jb.lock.Unlock()
+
+	return jb.drain(make([]*rtp.Packet, 0, len(jb.packets)))
+}
+
+// Stats returns a copy of the current counters.
+func (jb *JitterBuffer) Stats() JitterStats {
+	jb.lock.Lock()
+	defer jb.lock.Unlock()
+
+	return jb.stats
+}
+
+// pop emits consecutive packets starting at nextSeq.
+func (jb *JitterBuffer) pop(out []*rtp.Packet) []*rtp.Packet {
+	for {
+		packet, ok := jb.packets[jb.nextSeq]
+		if !ok {
+			return out
+		}
+		delete(jb.packets, jb.nextSeq)
+		out = append(out, jb.emit(packet))
+		jb.nextSeq++
+	}
+}
+
+// skipGap gives up waiting for the missing packets in front of the oldest
+// buffered packet and replaces them with silence. Gaps larger than the buffer
+// depth are no packet loss but a restarted stream or a sequence number jump,
+// so the output timeline continues right after the last packet instead.
+func (jb *JitterBuffer) skipGap(out []*rtp.Packet) []*rtp.Packet {
+	oldest, ok := jb.oldest()
+	if !ok {
+		return out
+	}
+	next := jb.packets[oldest]
+	missing := uint16(oldest - jb.nextSeq)
+
+	if int(missing) > jb.depth {
+		jb.stats.Resyncs++
+		jb.nextSeq = oldest
+		jb.rebase = jb.emitted
+		return out
+	}
+	jb.stats.Lost += uint64(missing)
+
+	// Spread the concealment frames evenly between the last emitted packet and
+	// the next real one. Without a previous packet fall back to the frame size.
+	step := jb.frameSamples
+	if jb.emitted && !jb.rebase {
+		span := next.Timestamp - jb.lastInputTS
+		if int32(span) > 0 && span/uint32(missing+1) > 0 {
+			step = span / uint32(missing+1)
+		}
+	}
+
+	for i := uint16(0); i < missing; i++ {
+		concealed := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    next.PayloadType,
+				SequenceNumber: jb.nextSeq,
+				Timestamp:      next.Timestamp - step*uint32(missing-i),
+				SSRC:           next.SSRC,
+			},
+			Payload: opusSilenceFrame,
+		}
+		out = append(out, jb.emit(concealed))
+		jb.stats.Concealed++
+		jb.nextSeq++
+	}
+
+	return out
+}
+
+// drain empties the buffer and fills the gaps between the remaining packets
+// with silence.
+func (jb *JitterBuffer) drain(out []*rtp.Packet) []*rtp.Packet {
+	for len(jb.packets) > 0 {
+		out = jb.pop(out)
+		if len(jb.packets) > 0 {
+			out = jb.skipGap(out)
+		}
+	}
+	return out
+}
+
+// oldest returns the buffered sequence number closest to nextSeq.
+func (jb *JitterBuffer) oldest() (uint16, bool) {
+	found := false
+	var oldest uint16
+	for seq := range jb.packets {
+		if !found || uint16(seq-jb.nextSeq) < uint16(oldest-jb.nextSeq) {
+			oldest = seq
+			found = true
+		}
+	}
+	return oldest, found
+}
+
+// emit translates the packet into the output timebase and keeps the output
+// timestamps strictly increasing.
+func (jb *JitterBuffer) emit(packet *rtp.Packet) *rtp.Packet {
+	if jb.emitted && !jb.rebase && packet.SequenceNumber == jb.lastSeq+1 {
+		delta := packet.Timestamp - jb.lastInputTS
+		if delta > 0 && delta <= maxOpusFrameSamples {
+			jb.frameSamples = delta
+		}
+	}
+
+	if jb.rebase {
+		jb.tsOffset = jb.lastOutputTS + jb.frameSamples - packet.Timestamp
+		jb.rebase = false
+	}
+
+	ts := packet.Timestamp + jb.tsOffset
+	if jb.emitted && int32(ts-jb.lastOutputTS) <= 0 {
+		ts = jb.lastOutputTS + jb.frameSamples
+		jb.tsOffset = ts - packet.Timestamp
+	}
+
+	jb.emitted = true
+	jb.lastSeq = packet.SequenceNumber
+	jb.lastInputTS = packet.Timestamp
+	jb.lastOutputTS = ts
+
+	packet.Timestamp = ts
+	return packet
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+func testPacket(seq uint16, ts uint32, ssrc uint32) *rtp.Packet {
+	return &rtp.Packet{
+		Header:  rtp.Header{Version: 2, SequenceNumber: seq, Timestamp: ts, SSRC: ssrc},
+		Payload: []byte{0x01},
+	}
+}
+
+type jitterPush struct {
+	seq  uint16
+	ts   uint32
+	ssrc uint32
+}
+
+func TestJitterBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		depth  int
+		pushes []jitterPush
+		flush  bool
+		// Sequence numbers and timestamps of the output, concealed packets
+		// are marked in concealed
+		wantSeq   []uint16
+		wantTS    []uint32
+		concealed []bool
+		wantStats JitterStats
+	}{
+		{
+			name:      "in order",
+			depth:     4,
+			pushes:    []jitterPush{{0, 0, 1}, {1, 960, 1}, {2, 1920, 1}},
+			wantSeq:   []uint16{0, 1, 2},
+			wantTS:    []uint32{0, 960, 1920},
+			concealed: []bool{false, false, false},
+			wantStats: JitterStats{Received: 3},
+		},
+		{
+			name:      "reordered",
+			depth:     4,
+			pushes:    []jitterPush{{0, 0, 1}, {2, 1920, 1}, {1, 960, 1}},
+			wantSeq:   []uint16{0, 1, 2},
+			wantTS:    []uint32{0, 960, 1920},
+			concealed: []bool{false, false, false},
+			wantStats: JitterStats{Received: 3, Reordered: 1},
+		},
+		{
+			name:      "duplicate and late",
+			depth:     4,
+			pushes:    []jitterPush{{0, 0, 1}, {2, 1920, 1}, {2, 1920, 1}, {0, 0, 1}, {1, 960, 1}},
+			wantSeq:   []uint16{0, 1, 2},
+			wantTS:    []uint32{0, 960, 1920},
+			concealed: []bool{false, false, false},
+			wantStats: JitterStats{Received: 5, Reordered: 1, Duplicates: 1, Late: 1},
+		},
+		{
+			name:      "sequence number wrap",
+			depth:     4,
+			pushes:    []jitterPush{{65534, 0, 1}, {65535, 960, 1}, {0, 1920, 1}, {1, 2880, 1}},
+			wantSeq:   []uint16{65534, 65535, 0, 1},
+			wantTS:    []uint32{0, 960, 1920, 2880},
+			concealed: []bool{false, false, false, false},
+			wantStats: JitterStats{Received: 4},
+		},
+		{
+			name:      "small gap is concealed",
+			depth:     2,
+			pushes:    []jitterPush{{0, 0, 1}, {2, 1920, 1}, {3, 2880, 1}, {4, 3840, 1}},
+			wantSeq:   []uint16{0, 1, 2, 3, 4},
+			wantTS:    []uint32{0, 960, 1920, 2880, 3840},
+			concealed: []bool{false, true, false, false, false},
+			wantStats: JitterStats{Received: 4, Lost: 1, Concealed: 1},
+		},
+		{
+			name:      "large gap resyncs",
+			depth:     2,
+			pushes:    []jitterPush{{0, 0, 1}, {1, 960, 1}, {1000, 960000, 1}, {1001, 960960, 1}, {1002, 961920, 1}},
+			wantSeq:   []uint16{0, 1, 1000, 1001, 1002},
+			wantTS:    []uint32{0, 960, 1920, 2880, 3840},
+			concealed: []bool{false, false, false, false, false},
+			wantStats: JitterStats{Received: 5, Resyncs: 1},
+		},
+		{
+			name:      "SSRC change continues the timeline",
+			depth:     4,
+			pushes:    []jitterPush{{0, 0, 1}, {1, 960, 1}, {500, 123456, 2}, {501, 124416, 2}},
+			wantSeq:   []uint16{0, 1, 500, 501},
+			wantTS:    []uint32{0, 960, 1920, 2880},
+			concealed: []bool{false, false, false, false},
+			wantStats: JitterStats{Received: 4, SSRCChanges: 1},
+		},
+		{
+			name:      "flush conceals remaining gaps",
+			depth:     10,
+			pushes:    []jitterPush{{0, 0, 1}, {2, 1920, 1}},
+			flush:     true,
+			wantSeq:   []uint16{0, 1, 2},
+			wantTS:    []uint32{0, 960, 1920},
+			concealed: []bool{false, true, false},
+			wantStats: JitterStats{Received: 2, Lost: 1, Concealed: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jb := NewJitterBuffer(tt.depth)
+			var out []*rtp.Packet
+			for _, p := range tt.pushes {
+				out = append(out, jb.Push(testPacket(p.seq, p.ts, p.ssrc))...)
+			}
+			if tt.flush {
+				out = append(out, jb.Flush()...)
+			}
+
+			if len(out) != len(tt.wantSeq) {
+				t.Fatalf("got %d packets, want %d", len(out), len(tt.wantSeq))
+			}
+			for i, packet := range out {
+				if packet.SequenceNumber != tt.wantSeq[i] {
+					t.Errorf("packet %d: seq %d, want %d", i, packet.SequenceNumber, tt.wantSeq[i])
+				}
+				if packet.Timestamp != tt.wantTS[i] {
+					t.Errorf("packet %d: timestamp %d, want %d", i, packet.Timestamp, tt.wantTS[i])
+				}
+				if isSilence := bytes.Equal(packet.Payload, opusSilenceFrame); isSilence != tt.concealed[i] {
+					t.Errorf("packet %d: concealed %v, want %v", i, isSilence, tt.concealed[i])
+				}
+			}
+			if stats := jb.Stats(); stats != tt.wantStats {
+				t.Errorf("stats %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// KeyVerifier checks the public key of the transcription server before the
+// secret token is sent. Keys are identified by the SHA-256 fingerprint of
+// their DER encoded SubjectPublicKeyInfo, written as "SHA256:<base64>".
+//
+// If pins are configured, the key must match one of them. Otherwise, if a
+// known hosts file is configured, the first key seen for a host is stored
+// there and must match on every later connection (trust on first use).
+type KeyVerifier struct {
+	lock           sync.Mutex
+	pins           []string
+	knownHostsFile string
+}
+
+func NewKeyVerifier(pins []string, knownHostsFile string) *KeyVerifier {
+	normalized := make([]string, 0, len(pins))
+	for _, pin := range pins {
+		pin = normalizeFingerprint(pin)
+		if pin != "" {
+			normalized = append(normalized, pin)
+		}
+	}
+	return &KeyVerifier{
+		pins:           normalized,
+		knownHostsFile: knownHostsFile,
+	}
+}
+
+// Fingerprint returns the fingerprint of a DER encoded public key.
+func Fingerprint(spki []byte) string {
+	sum := sha256.Sum256(spki)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint accepts "SHA256:<base64>", "sha256/<base64>" and plain
+// base64, with or without padding.
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.TrimSpace(fingerprint)
+	if fingerprint == "" {
+		return ""
+	}
+	for _, prefix := range []string{"SHA256:", "sha256:", "sha256/"} {
+		fingerprint = strings.TrimPrefix(fingerprint, prefix)
+	}
+	return "SHA256:" + strings.TrimRight(fingerprint, "=")
+}
+
+// Verify checks the DER encoded public key the server at host presented.
+func (v *KeyVerifier) Verify(host string, spki []byte) error {
+	fingerprint := Fingerprint(spki)
+
+	if len(v.pins) > 0 {
+		for _, pin := range v.pins {
+			if pin == fingerprint {
+				return nil
+			}
+		}
+		return fmt.Errorf("server public key of %s does not match any pinned key: got %s, expected one of %s",
+			host, fingerprint, strings.Join(v.pins, ", "))
+	}
+
+	if v.knownHostsFile == "" {
+		return nil
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	known, err := v.readKnownHosts()
+	if err != nil {
+		return err
+	}
+	if expected, ok := known[host]; ok {
+		if expected != fingerprint {
+			return fmt.Errorf("server public key of %s has changed: got %s, known hosts file %s has %s",
+				host, fingerprint, v.knownHostsFile, expected)
+		}
+		return nil
+	}
+
+	// First connection to this host: trust and remember the key
+	file, err := os.OpenFile(v.knownHostsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open known hosts file: %w", err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, "%s %s\n", host, fingerprint); err != nil {
+		return fmt.Errorf("could not write known hosts file: %w", err)
+	}
+	log.Printf("[INFO] Added %s (%s) to known hosts", host, fingerprint)
+	return nil
+}
+
+// readKnownHosts parses lines of "host fingerprint". Empty lines and lines
+// starting with # are ignored.
+func (v *KeyVerifier) readKnownHosts() (map[string]string, error) {
+	known := make(map[string]string)
+
+	file, err := os.Open(v.knownHostsFile)
+	if os.IsNotExist(err) {
+		return known, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open known hosts file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		known[fields[0]] = normalizeFingerprint(fields[1])
+	}
+	return known, scanner.Err()
+}
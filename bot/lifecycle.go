@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bigbluebutton-bot/bigbluebutton-bot/api"
+)
+
+// Reasons why a bot left its meeting
+const (
+	LeaveRequested     = "requested"
+	LeaveJoinFailed    = "join_failed"
+	LeaveIdle          = "idle_timeout"
+	LeaveAlone         = "alone"
+	LeaveMaxDuration   = "max_duration"
+	LeaveMeetingEnded  = "meeting_ended"
+	LeaveScheduleEnded = "schedule_ended"
+)
+
+// LifecyclePolicy decides when a bot leaves its meeting on its own. Zero
+// values disable the respective rule.
+type LifecyclePolicy struct {
+	// Leave after this many minutes without speech
+	IdleTimeoutMinutes int `json:"idle_timeout_minutes" minimum:"0" doc:"Leave after this many minutes without speech, 0 disables"`
+	// Leave when the bot and its sub-bots are the only participants
+	LeaveWhenAlone bool `json:"leave_when_alone" doc:"Leave when no other participant is in the meeting"`
+	// How long the bot waits for someone to come back before leaving
+	AloneGraceMinutes int `json:"alone_grace_minutes" minimum:"0" doc:"Minutes to wait for other participants before leaving"`
+	// Leave after this many minutes in the meeting
+	MaxDurationMinutes int `json:"max_duration_minutes" minimum:"0" doc:"Leave after this many minutes, 0 disables"`
+}
+
+// leaveReason returns why the bot should leave now, or "" if it can stay.
+// meeting is nil if the meeting is unknown.
+func (b *Bot) leaveReason(now time.Time, meeting *api.Meeting) string {
+	if b.Status != Connected {
+		return ""
+	}
+	b.policyLock.Lock()
+	defer b.policyLock.Unlock()
+	policy := b.Policy
+
+	if policy.MaxDurationMinutes > 0 &&
+		now.Sub(b.JoinedAt) >= time.Duration(policy.MaxDurationMinutes)*time.Minute {
+		return LeaveMaxDuration
+	}
+
+	if policy.IdleTimeoutMinutes > 0 &&
+		now.Sub(b.silencegate.LastVoice()) >= time.Duration(policy.IdleTimeoutMinutes)*time.Minute {
+		return LeaveIdle
+	}
+
+	if policy.LeaveWhenAlone && meeting != nil {
+		if b.othersPresent(meeting) {
+			b.aloneSince = time.Time{}
+		} else {
+			if b.aloneSince.IsZero() {
+				b.aloneSince = now
+			}
+			if now.Sub(b.aloneSince) >= time.Duration(policy.AloneGraceMinutes)*time.Minute {
+				return LeaveAlone
+			}
+		}
+	}
+	return ""
+}
+
+// SetPolicy replaces the lifecycle policy of the bot.
+func (b *Bot) SetPolicy(policy LifecyclePolicy) {
+	b.policyLock.Lock()
+	defer b.policyLock.Unlock()
+
+	b.Policy = policy
+}
+
+// othersPresent reports whether anyone besides the bot and its sub-bots (named
+// UserName-<lang>) is in the meeting.
+func (b *Bot) othersPresent(meeting *api.Meeting) bool {
+	for _, attendee := range meeting.Attendees {
+		if attendee.FullName == b.UserName || strings.HasPrefix(attendee.FullName, b.UserName+"-") {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// EnforcePolicies removes all bots whose lifecycle policy says they should
+// leave. meetings is only called if a bot needs the participant list.
+func (bm *BotManager) EnforcePolicies(meetings func() (map[string]api.Meeting, error)) {
+	now := time.Now()
+
+	var running map[string]api.Meeting
+	leave := make(map[string]string)
+	for _, bot := range bm.botList() {
+		var meeting *api.Meeting
+		bot.policyLock.Lock()
+		whenAlone := bot.Policy.LeaveWhenAlone
+		bot.policyLock.Unlock()
+		if whenAlone {
+			if running == nil {
+				var err error
+				if running, err = meetings(); err != nil {
+					log.Printf("[ERROR] Failed to fetch meetings for lifecycle policies: %v", err)
+					running = make(map[string]api.Meeting)
+				}
+			}
+			if m, ok := running[bot.MeetingID]; ok {
+				meeting = &m
+			}
+		}
+		if reason := bot.leaveReason(now, meeting); reason != "" {
+			leave[bot.ID] = reason
+		}
+	}
+
+	for botID, reason := range leave {
+		bm.RemoveBot(botID, reason)
+	}
+}
+
+// RunPolicies enforces the lifecycle policies every interval until stop is
+// closed.
+func (bm *BotManager) RunPolicies(interval time.Duration, meetings func() (map[string]api.Meeting, error), stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			bm.EnforcePolicies(meetings)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bigbluebutton-bot/bigbluebutton-bot/api"
+)
+
+func testMeeting(names ...string) *api.Meeting {
+	meeting := &api.Meeting{}
+	for _, name := range names {
+		meeting.Attendees = append(meeting.Attendees, api.Attendee{FullName: name})
+	}
+	return meeting
+}
+
+func TestOthersPresent(t *testing.T) {
+	b := &Bot{UserName: "Bot"}
+	tests := []struct {
+		name    string
+		meeting *api.Meeting
+		want    bool
+	}{
+		{"empty", testMeeting(), false},
+		{"bot only", testMeeting("Bot"), false},
+		{"bot and sub-bots", testMeeting("Bot", "Bot-de", "Bot-fr"), false},
+		{"participant", testMeeting("Bot", "Alice"), true},
+		{"similar name", testMeeting("Bot", "Bottom"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.othersPresent(tt.meeting); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaveReason(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		status     StatusType
+		policy     LifecyclePolicy
+		joined     time.Duration // before now
+		lastVoice  time.Duration // before now
+		aloneSince time.Duration // before now, 0 if not alone yet
+		meeting    *api.Meeting
+		want       string
+	}{
+		{"no policy", Connected, LifecyclePolicy{}, 5 * time.Hour, 5 * time.Hour, 0, testMeeting("Bot"), ""},
+		{"not connected", Connecting, LifecyclePolicy{MaxDurationMinutes: 1}, time.Hour, 0, 0, nil, ""},
+		{"max duration reached", Connected, LifecyclePolicy{MaxDurationMinutes: 60}, time.Hour, 0, 0, nil, LeaveMaxDuration},
+		{"max duration not reached", Connected, LifecyclePolicy{MaxDurationMinutes: 60}, 59 * time.Minute, 0, 0, nil, ""},
+		{"idle", Connected, LifecyclePolicy{IdleTimeoutMinutes: 10}, time.Hour, 10 * time.Minute, 0, nil, LeaveIdle},
+		{"speaking", Connected, LifecyclePolicy{IdleTimeoutMinutes: 10}, time.Hour, 9 * time.Minute, 0, nil, ""},
+		{"max duration before idle", Connected, LifecyclePolicy{IdleTimeoutMinutes: 10, MaxDurationMinutes: 30}, time.Hour, time.Hour, 0, nil, LeaveMaxDuration},
+		{"alone without grace", Connected, LifecyclePolicy{LeaveWhenAlone: true}, time.Hour, 0, 0, testMeeting("Bot", "Bot-de"), LeaveAlone},
+		{"alone within grace", Connected, LifecyclePolicy{LeaveWhenAlone: true, AloneGraceMinutes: 5}, time.Hour, 0, 4 * time.Minute, testMeeting("Bot"), ""},
+		{"alone after grace", Connected, LifecyclePolicy{LeaveWhenAlone: true, AloneGraceMinutes: 5}, time.Hour, 0, 5 * time.Minute, testMeeting("Bot"), LeaveAlone},
+		{"not alone", Connected, LifecyclePolicy{LeaveWhenAlone: true}, time.Hour, 0, time.Hour, testMeeting("Bot", "Alice"), ""},
+		{"meeting unknown", Connected, LifecyclePolicy{LeaveWhenAlone: true}, time.Hour, 0, time.Hour, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{
+				Status:      tt.status,
+				UserName:    "Bot",
+				Policy:      tt.policy,
+				JoinedAt:    now.Add(-tt.joined),
+				silencegate: NewSilenceGate(SilenceGateConfig{}),
+			}
+			b.silencegate.lastVoice = now.Add(-tt.lastVoice)
+			if tt.aloneSince > 0 {
+				b.aloneSince = now.Add(-tt.aloneSince)
+			}
+			if got := b.leaveReason(now, tt.meeting); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaveReasonAloneGrace(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	b := &Bot{
+		Status:      Connected,
+		UserName:    "Bot",
+		Policy:      LifecyclePolicy{LeaveWhenAlone: true, AloneGraceMinutes: 5},
+		JoinedAt:    now,
+		silencegate: NewSilenceGate(SilenceGateConfig{}),
+	}
+
+	// The grace period starts when the bot is first seen alone
+	if got := b.leaveReason(now, testMeeting("Bot")); got != "" {
+		t.Fatalf("left right away: %q", got)
+	}
+	// Someone came back, the next time alone starts a new grace period
+	if got := b.leaveReason(now.Add(3*time.Minute), testMeeting("Bot", "Alice")); got != "" {
+		t.Fatalf("left with a participant: %q", got)
+	}
+	if got := b.leaveReason(now.Add(4*time.Minute), testMeeting("Bot")); got != "" {
+		t.Fatalf("left before a new grace period: %q", got)
+	}
+	if got := b.leaveReason(now.Add(8*time.Minute), testMeeting("Bot")); got != "" {
+		t.Fatalf("left before the grace period ended: %q", got)
+	}
+	if got := b.leaveReason(now.Add(9*time.Minute), testMeeting("Bot")); got != LeaveAlone {
+		t.Errorf("got %q, want %q", got, LeaveAlone)
+	}
+}
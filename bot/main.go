@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	bbbbot "github.com/bigbluebutton-bot/bigbluebutton-bot"
@@ -21,9 +24,15 @@ import (
 // -----------------------------------------------------------------------------
 
 var (
-	conf    *Settings
-	BM      *BotManager
-	bbb_api *bbbapi.ApiRequest
+	conf               *Settings
+	BM                 *BotManager
+	bbb_api            *bbbapi.ApiRequest
+	transcription_pool *TranscriptionPool
+	watchdog           *Watchdog
+	quotas             *Quotas
+	scheduler          *Scheduler
+	webhooks           *Webhooks
+	bbb_webhooks       *BBBWebhooks
 )
 
 // -----------------------------------------------------------------------------
@@ -43,12 +52,39 @@ type statusResponse struct {
 	MaxBots   int `json:"max_bots"`
 }
 
+type healthResponse struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies,omitempty"`
+}
+
 type StatusOutput struct{ Body statusResponse }
 type MeetingsOutput struct{ Body []bbbapi.Meeting }
 type MeetingOutput struct{ Body bbbapi.Meeting }
 type LanguagesOutput struct{ Body map[string]string }
 type BotsOutput struct{ Body map[string]*Bot }
 type BotOutput struct{ Body *Bot }
+type TranscriptionServersOutput struct{ Body []TranscriptionServer }
+type SchedulesOutput struct{ Body []Schedule }
+type ScheduleOutput struct{ Body Schedule }
+type CaptionSinksOutput struct{ Body []CaptionSinkInfo }
+type CaptionSinkOutput struct{ Body CaptionSinkInfo }
+type WebhooksOutput struct{ Body []WebhookSubscription }
+type WebhookOutput struct{ Body WebhookSubscription }
+type WebhookDeliveriesOutput struct{ Body []WebhookDelivery }
+type PolicyOutput struct{ Body LifecyclePolicy }
+type HistoryOutput struct{ Body map[string][]HistoryEntry }
+type HLSRenditionsOutput struct{ Body []HLSRendition }
+
+// Playlists and segments are not JSON
+type HLSOutput struct {
+	ContentType  string `header:"Content-Type"`
+	CacheControl string `header:"Cache-Control"`
+	Body         []byte
+}
+type HealthOutput struct {
+	Status int
+	Body   healthResponse
+}
 
 // -----------------------------------------------------------------------------
 // Huma route registration
@@ -73,6 +109,56 @@ func addRoutes(api huma.API) {
 		}, nil
 	})
 
+	huma.Register(api, huma.Operation{
+		OperationID: "get-healthz",
+		Method:      http.MethodGet,
+		Path:        "/healthz",
+		Summary:     "Liveness probe",
+		Tags:        []string{"System"},
+	}, func(_ context.Context, _ *struct{}) (*HealthOutput, error) {
+		return &HealthOutput{
+			Status: http.StatusOK,
+			Body:   healthResponse{Status: "ok"},
+		}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-readyz",
+		Method:      http.MethodGet,
+		Path:        "/readyz",
+		Summary:     "Readiness probe with the status of all dependencies",
+		Tags:        []string{"System"},
+	}, func(_ context.Context, _ *struct{}) (*HealthOutput, error) {
+		deps := watchdog.Statuses()
+
+		out := &HealthOutput{
+			Status: http.StatusOK,
+			Body:   healthResponse{Status: "ok", Dependencies: deps},
+		}
+		if err := watchdog.Ready(); err != nil {
+			out.Status = http.StatusServiceUnavailable
+			out.Body.Status = "unavailable"
+			return out, nil
+		}
+		for _, dep := range deps {
+			if !dep.Healthy {
+				out.Body.Status = "degraded"
+				break
+			}
+		}
+		return out, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-transcription-servers",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/transcription/servers",
+		Summary:     "List transcription servers and their load",
+		Tags:        []string{"System"},
+	}, func(_ context.Context, _ *struct{}) (*TranscriptionServersOutput, error) {
+		return &TranscriptionServersOutput{Body: transcription_pool.Servers()}, nil
+	})
+
 	// -------------------------------------------------------------------------
 	// BBB meetings
 	// -------------------------------------------------------------------------
@@ -166,7 +252,7 @@ func addRoutes(api huma.API) {
 		Tags:        []string{"Bots"},
 	}, func(_ context.Context, _ *struct{}) (*BotsOutput, error) {
 		for _, bot := range BM.Bots() {
-			bot.Sub_bots = len(bot.clients)
+			bot.updateStats()
 		}
 
 		return &BotsOutput{Body: BM.Bots()}, nil
@@ -185,7 +271,7 @@ func addRoutes(api huma.API) {
 		if !ok {
 			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
 		}
-		bot.Sub_bots = len(bot.clients)
+		bot.updateStats()
 		return &BotOutput{Body: bot}, nil
 	})
 
@@ -196,10 +282,26 @@ func addRoutes(api huma.API) {
 		Summary:       "Create a bot and join a meeting",
 		Tags:          []string{"Bots"},
 		DefaultStatus: http.StatusOK,
-	}, func(_ context.Context, input *struct {
-		MeetingID string `path:"meeting_id" doc:"Meeting ID"`
+	}, func(ctx context.Context, input *struct {
+		MeetingID      string `path:"meeting_id" doc:"Meeting ID"`
+		SourceLanguage string `query:"source_language" doc:"Language spoken in the meeting, defaults to BOT_SOURCE_LANGUAGE"`
 	}) (*BotOutput, error) {
 		log.Printf("[INFO] bot-join called for meeting_id=%s", input.MeetingID)
+		if err := watchdog.Ready(); err != nil {
+			log.Printf("[WARN] Refusing to join meeting %s: %v", input.MeetingID, err)
+			return nil, huma.NewError(http.StatusServiceUnavailable, err.Error())
+		}
+		client := clientFromContext(ctx)
+		if err := quotas.ReserveBot(client); err != nil {
+			log.Printf("[WARN] Refusing to join meeting %s: %v", input.MeetingID, err)
+			return nil, err
+		}
+		owned := false
+		defer func() {
+			if !owned {
+				quotas.ReleaseBot(client)
+			}
+		}()
 		// check if there is already a bot in this meeting
 		for _, bot := range BM.Bots() {
 			log.Printf("[DEBUG] Checking bot %s in meeting %s", bot.ID, bot.MeetingID)
@@ -216,6 +318,10 @@ func addRoutes(api huma.API) {
 			return nil, huma.NewError(http.StatusTooManyRequests, "Max bots limit reached")
 		}
 
+		if input.SourceLanguage != "" && !isValidLanguage(input.SourceLanguage) {
+			return nil, huma.NewError(http.StatusBadRequest, "Invalid source language")
+		}
+
 		log.Printf("[INFO] Fetching meetings from BBB API")
 		meetings, err := bbb_api.GetMeetings()
 		if err != nil {
@@ -233,9 +339,19 @@ func addRoutes(api huma.API) {
 			log.Printf("[ERROR] Failed to create bot: %v", err)
 			return nil, huma.NewError(http.StatusInternalServerError, "Failed to create bot")
 		}
+		quotas.SetOwner(bot.ID, client)
+		owned = true
+		if input.SourceLanguage != "" {
+			if err := bot.SetSourceLanguage(input.SourceLanguage); err != nil {
+				log.Printf("[ERROR] Failed to set source language of bot %s: %v", bot.ID, err)
+				BM.RemoveBot(bot.ID, LeaveJoinFailed)
+				return nil, huma.NewError(http.StatusInternalServerError, "Failed to set source language")
+			}
+		}
 		log.Printf("[INFO] Bot %s created, joining meeting %s", bot.ID, input.MeetingID)
 		if err := bot.Join(input.MeetingID, "Bot"); err != nil {
 			log.Printf("[ERROR] Failed to join meeting %s: %v", input.MeetingID, err)
+			BM.RemoveBot(bot.ID, LeaveJoinFailed)
 			return nil, huma.NewError(http.StatusInternalServerError, "Failed to join meeting")
 		}
 		log.Printf("[INFO] Bot %s successfully joined meeting %s", bot.ID, input.MeetingID)
@@ -259,11 +375,221 @@ func addRoutes(api huma.API) {
 			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
 		}
 		log.Printf("[INFO] Removing bot %s", input.BotID)
-		BM.RemoveBot(input.BotID)
+		BM.RemoveBot(input.BotID, LeaveRequested)
 		log.Printf("[INFO] Bot %s removed successfully", input.BotID)
 		return nil, nil
 	})
 
+	huma.Register(api, huma.Operation{
+		OperationID:   "bot-set-source-language",
+		Method:        http.MethodPut,
+		Path:          "/api/v1/bot/{bot_id}/source/{lang}",
+		Summary:       "Set the language spoken in the meeting",
+		Tags:          []string{"Bots"},
+		DefaultStatus: http.StatusOK,
+	}, func(_ context.Context, input *struct {
+		BotID string `path:"bot_id" doc:"Bot ID"`
+		Lang  string `path:"lang"  doc:"Language code"`
+	}) (*BotOutput, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		if !isValidLanguage(input.Lang) {
+			return nil, huma.NewError(http.StatusBadRequest, "Invalid language code")
+		}
+		if err := bot.SetSourceLanguage(input.Lang); err != nil {
+			log.Printf("[ERROR] Failed to set source language of bot %s: %v", bot.ID, err)
+			return nil, huma.NewError(http.StatusInternalServerError, "Failed to set source language")
+		}
+		return &BotOutput{Body: bot}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-bot-history",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/bot/{bot_id}/history",
+		Summary:     "Get the caption text trimmed from the pads",
+		Tags:        []string{"Bots"},
+	}, func(_ context.Context, input *struct {
+		BotID string `path:"bot_id" doc:"Bot ID"`
+		Lang  string `query:"lang" doc:"Only return this language"`
+	}) (*HistoryOutput, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		return &HistoryOutput{Body: bot.History(input.Lang)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-bot-hls",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/bot/{bot_id}/hls",
+		Summary:     "List the WebVTT subtitle renditions of the captions",
+		Description: "Each rendition is a live HLS media playlist. Add the media tags to the master playlist of the livestream to show the captions in HLS players.",
+		Tags:        []string{"Bots"},
+	}, func(_ context.Context, input *struct {
+		BotID string `path:"bot_id" doc:"Bot ID"`
+	}) (*HLSRenditionsOutput, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		renditions := make([]HLSRendition, 0)
+		for _, lang := range bot.HLS().Languages() {
+			renditions = append(renditions, NewHLSRendition(bot.ID, lang, bot.HLS().Start()))
+		}
+		return &HLSRenditionsOutput{Body: renditions}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-bot-hls-playlist",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/bot/{bot_id}/hls/{lang}/playlist.m3u8",
+		Summary:     "Get the live WebVTT subtitle playlist of a language",
+		Tags:        []string{"Bots"},
+		Responses: map[string]*huma.Response{
+			"200": {Content: map[string]*huma.MediaType{"application/vnd.apple.mpegurl": {}}},
+		},
+		// Players poll the playlist every segment
+		Metadata: map[string]any{rateLimitExempt: true},
+	}, func(_ context.Context, input *struct {
+		BotID  string `path:"bot_id" doc:"Bot ID"`
+		Lang   string `path:"lang" doc:"Language code"`
+		MPEGTS int64  `query:"mpegts" minimum:"0" doc:"MPEG-TS timestamp (90 kHz) of the video at the start of the rendition, passed on to the segments"`
+	}) (*HLSOutput, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		playlist, ok := bot.HLS().Playlist(input.Lang, input.MPEGTS)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "No captions in this language")
+		}
+		return &HLSOutput{
+			ContentType:  "application/vnd.apple.mpegurl",
+			CacheControl: "no-cache",
+			Body:         []byte(playlist),
+		}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-bot-hls-segment",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/bot/{bot_id}/hls/{lang}/{segment}",
+		Summary:     "Get a WebVTT subtitle segment",
+		Tags:        []string{"Bots"},
+		Responses: map[string]*huma.Response{
+			"200": {Content: map[string]*huma.MediaType{"text/vtt": {}}},
+		},
+		Metadata: map[string]any{rateLimitExempt: true},
+	}, func(_ context.Context, input *struct {
+		BotID   string `path:"bot_id" doc:"Bot ID"`
+		Lang    string `path:"lang" doc:"Language code"`
+		Segment string `path:"segment" doc:"Segment file name from the playlist, e.g. 12.vtt"`
+		MPEGTS  int64  `query:"mpegts" minimum:"0" doc:"MPEG-TS timestamp (90 kHz) of the video at the start of the rendition"`
+	}) (*HLSOutput, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(input.Segment, ".vtt"))
+		if err != nil || !strings.HasSuffix(input.Segment, ".vtt") {
+			return nil, huma.NewError(http.StatusNotFound, "Segment not found")
+		}
+		segment, ok := bot.HLS().Segment(input.Lang, seq, input.MPEGTS)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Segment not found")
+		}
+		// Finished segments never change
+		return &HLSOutput{
+			ContentType:  "text/vtt",
+			CacheControl: "max-age=3600",
+			Body:         []byte(segment),
+		}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-bot-sinks",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/bot/{bot_id}/sinks",
+		Summary:     "List the additional destinations of the captions",
+		Tags:        []string{"Bots"},
+	}, func(_ context.Context, input *struct {
+		BotID string `path:"bot_id" doc:"Bot ID"`
+	}) (*CaptionSinksOutput, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		return &CaptionSinksOutput{Body: bot.Sinks()}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-bot-sink",
+		Method:        http.MethodPost,
+		Path:          "/api/v1/bot/{bot_id}/sinks",
+		Summary:       "Send the captions to another destination",
+		Description:   "Every caption update is sent as JSON: appended as a line to a file in CAPTION_SINK_DIR, POSTed to a webhook or sent as a WebSocket text message. Only languages the bot captions are sent. Webhook requests are signed like the lifecycle webhooks, the secret is only returned by this call.",
+		Tags:          []string{"Bots"},
+		DefaultStatus: http.StatusCreated,
+	}, func(_ context.Context, input *struct {
+		BotID string `path:"bot_id" doc:"Bot ID"`
+		Body  CaptionSinkSpec
+	}) (*CaptionSinkOutput, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		sink, err := bot.AddSink(input.Body)
+		if err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, err.Error())
+		}
+		log.Printf("[INFO] Bot %s: %s sink %s created for %s", bot.ID, sink.Type, sink.ID, sink.Target)
+		return &CaptionSinkOutput{Body: sink}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-bot-sink",
+		Method:        http.MethodDelete,
+		Path:          "/api/v1/bot/{bot_id}/sink/{sink_id}",
+		Summary:       "Stop sending the captions to a destination",
+		Tags:          []string{"Bots"},
+		DefaultStatus: http.StatusNoContent,
+	}, func(_ context.Context, input *struct {
+		BotID  string `path:"bot_id" doc:"Bot ID"`
+		SinkID string `path:"sink_id" doc:"Sink ID"`
+	}) (*struct{}, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		if !bot.RemoveSink(input.SinkID) {
+			return nil, huma.NewError(http.StatusNotFound, "Sink not found")
+		}
+		log.Printf("[INFO] Bot %s: sink %s deleted", bot.ID, input.SinkID)
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "bot-set-policy",
+		Method:      http.MethodPut,
+		Path:        "/api/v1/bot/{bot_id}/policy",
+		Summary:     "Set when the bot leaves its meeting on its own",
+		Tags:        []string{"Bots"},
+	}, func(_ context.Context, input *struct {
+		BotID string `path:"bot_id" doc:"Bot ID"`
+		Body  LifecyclePolicy
+	}) (*PolicyOutput, error) {
+		bot, ok := BM.Bot(input.BotID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Bot not found")
+		}
+		bot.SetPolicy(input.Body)
+		return &PolicyOutput{Body: input.Body}, nil
+	})
+
 	huma.Register(api, huma.Operation{
 		OperationID:   "bot-set-task",
 		Method:        http.MethodPut,
@@ -299,7 +625,7 @@ func addRoutes(api huma.API) {
 		Summary:       "Start translation",
 		Tags:          []string{"Bots"},
 		DefaultStatus: http.StatusOK,
-	}, func(_ context.Context, input *struct {
+	}, func(ctx context.Context, input *struct {
 		BotID string `path:"bot_id" doc:"Bot ID"`
 		Lang  string `path:"lang"  doc:"Language code"`
 	}) (*struct{}, error) {
@@ -324,6 +650,10 @@ func addRoutes(api huma.API) {
 			return nil, huma.NewError(http.StatusBadRequest, "Bot is not in translate mode")
 		}
 
+		if err := quotas.CheckLanguages(clientFromContext(ctx)); err != nil {
+			return nil, err
+		}
+
 		if err := bot.Translate(input.Lang); err != nil {
 			return nil, huma.NewError(http.StatusInternalServerError, "Failed to start translation")
 		}
@@ -368,12 +698,208 @@ func addRoutes(api huma.API) {
 		}
 		return nil, nil
 	})
+
+	// -------------------------------------------------------------------------
+	// Schedules
+	// -------------------------------------------------------------------------
+	huma.Register(api, huma.Operation{
+		OperationID: "get-schedules",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/schedules",
+		Summary:     "List scheduled bot joins",
+		Tags:        []string{"Schedules"},
+	}, func(_ context.Context, _ *struct{}) (*SchedulesOutput, error) {
+		return &SchedulesOutput{Body: scheduler.Schedules()}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-schedule",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/schedule/{schedule_id}",
+		Summary:     "Get a scheduled bot join",
+		Tags:        []string{"Schedules"},
+	}, func(_ context.Context, input *struct {
+		ScheduleID string `path:"schedule_id" doc:"Schedule ID"`
+	}) (*ScheduleOutput, error) {
+		schedule, ok := scheduler.Schedule(input.ScheduleID)
+		if !ok {
+			return nil, huma.NewError(http.StatusNotFound, "Schedule not found")
+		}
+		return &ScheduleOutput{Body: schedule}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-schedule",
+		Method:        http.MethodPost,
+		Path:          "/api/v1/schedules",
+		Summary:       "Schedule a bot to join a meeting",
+		Tags:          []string{"Schedules"},
+		DefaultStatus: http.StatusCreated,
+	}, func(_ context.Context, input *struct {
+		Body ScheduleSpec
+	}) (*ScheduleOutput, error) {
+		for _, lang := range input.Body.Languages {
+			if !isValidLanguage(lang) {
+				return nil, huma.NewError(http.StatusBadRequest, "Invalid language code: "+lang)
+			}
+		}
+		schedule, err := scheduler.Add(input.Body)
+		if err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, err.Error())
+		}
+		log.Printf("[INFO] Schedule %s created", schedule.ID)
+		return &ScheduleOutput{Body: schedule}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-schedule",
+		Method:        http.MethodDelete,
+		Path:          "/api/v1/schedule/{schedule_id}",
+		Summary:       "Delete a scheduled bot join",
+		Tags:          []string{"Schedules"},
+		DefaultStatus: http.StatusNoContent,
+	}, func(_ context.Context, input *struct {
+		ScheduleID string `path:"schedule_id" doc:"Schedule ID"`
+	}) (*struct{}, error) {
+		if !scheduler.Remove(input.ScheduleID) {
+			return nil, huma.NewError(http.StatusNotFound, "Schedule not found")
+		}
+		log.Printf("[INFO] Schedule %s deleted", input.ScheduleID)
+		return nil, nil
+	})
+
+	// -------------------------------------------------------------------------
+	// Webhooks
+	// -------------------------------------------------------------------------
+	huma.Register(api, huma.Operation{
+		OperationID: "get-webhooks",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/webhooks",
+		Summary:     "List webhook subscriptions",
+		Tags:        []string{"Webhooks"},
+	}, func(_ context.Context, _ *struct{}) (*WebhooksOutput, error) {
+		return &WebhooksOutput{Body: webhooks.Subscriptions()}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-webhook",
+		Method:        http.MethodPost,
+		Path:          "/api/v1/webhooks",
+		Summary:       "Subscribe to bot lifecycle events",
+		Description:   "Events are POSTed as JSON to a host in WEBHOOK_HOSTS, redirects are not followed. The X-Webhook-Signature header is sha256=hex(HMAC-SHA256(secret, X-Webhook-Timestamp + \".\" + body)). The secret is only returned by this call.",
+		Tags:          []string{"Webhooks"},
+		DefaultStatus: http.StatusCreated,
+	}, func(_ context.Context, input *struct {
+		Body WebhookSpec
+	}) (*WebhookOutput, error) {
+		subscription, err := webhooks.Subscribe(input.Body)
+		if err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, err.Error())
+		}
+		log.Printf("[INFO] Webhook %s created for %s", subscription.ID, subscription.URL)
+		return &WebhookOutput{Body: subscription}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-webhook",
+		Method:        http.MethodDelete,
+		Path:          "/api/v1/webhook/{webhook_id}",
+		Summary:       "Delete a webhook subscription",
+		Tags:          []string{"Webhooks"},
+		DefaultStatus: http.StatusNoContent,
+	}, func(_ context.Context, input *struct {
+		WebhookID string `path:"webhook_id" doc:"Webhook ID"`
+	}) (*struct{}, error) {
+		if !webhooks.Unsubscribe(input.WebhookID) {
+			return nil, huma.NewError(http.StatusNotFound, "Webhook not found")
+		}
+		log.Printf("[INFO] Webhook %s deleted", input.WebhookID)
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-webhook-deliveries",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/webhooks/deliveries",
+		Summary:     "List the latest webhook delivery attempts",
+		Tags:        []string{"Webhooks"},
+	}, func(_ context.Context, input *struct {
+		WebhookID string `query:"webhook_id" doc:"Only deliveries of this webhook"`
+	}) (*WebhookDeliveriesOutput, error) {
+		return &WebhookDeliveriesOutput{Body: webhooks.Deliveries(input.WebhookID)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "bbb-webhook",
+		Method:        http.MethodPost,
+		Path:          "/api/v1/bbb/webhook",
+		Summary:       "Callback for BBB server webhooks",
+		Description:   "Joins bots to new meetings and removes bots of ended meetings. Requests must carry the BBB checksum and a timestamp of the last ten minutes.",
+		Tags:          []string{"BBB"},
+		DefaultStatus: http.StatusOK,
+		// Checked by the checksum, BBB must not lose events to the limit
+		Metadata: map[string]any{rateLimitExempt: true},
+	}, func(_ context.Context, input *struct {
+		Checksum string `query:"checksum" doc:"BBB checksum"`
+		RawBody  []byte `contentType:"application/x-www-form-urlencoded"`
+	}) (*struct{}, error) {
+		if bbb_webhooks == nil {
+			return nil, huma.NewError(http.StatusNotFound, "BBB webhooks are not enabled")
+		}
+		form, err := url.ParseQuery(string(input.RawBody))
+		if err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, "Invalid form body")
+		}
+		if err := bbb_webhooks.Verify(input.Checksum, form, input.RawBody); err != nil {
+			log.Printf("[WARN] Rejected BBB webhook: %v", err)
+			return nil, huma.NewError(http.StatusUnauthorized, "Invalid callback: "+err.Error())
+		}
+		if err := bbb_webhooks.Handle(form); err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, err.Error())
+		}
+		return nil, nil
+	})
 }
 
 // -----------------------------------------------------------------------------
 // Helpers
 // -----------------------------------------------------------------------------
 
+// joinMeeting starts a bot in the meeting without an API request, e.g. for
+// schedules and BBB webhooks.
+func joinMeeting(meetingID string, task string, languages []string) (*Bot, error) {
+	if err := watchdog.Ready(); err != nil {
+		return nil, err
+	}
+	for _, bot := range BM.botList() {
+		if bot.MeetingID == meetingID {
+			return nil, fmt.Errorf("bot already in meeting")
+		}
+	}
+
+	bot, err := BM.AddBot()
+	if err != nil {
+		return nil, err
+	}
+	if err := bot.Join(meetingID, "Bot"); err != nil {
+		BM.RemoveBot(bot.ID, LeaveJoinFailed)
+		return nil, err
+	}
+
+	if task == "translate" {
+		bot.SetTask(TaskTranslate)
+		for _, lang := range languages {
+			if lang == bot.SourceLanguage {
+				continue
+			}
+			if err := bot.Translate(lang); err != nil {
+				log.Printf("[ERROR] Failed to translate meeting %s to %s: %v", meetingID, lang, err)
+			}
+		}
+	}
+	return bot, nil
+}
+
 func isValidLanguage(lang string) bool {
 	log.Printf("[DEBUG] Validating language: %s", lang)
 	for _, c := range bbbbot.AllLanguages() {
@@ -386,20 +912,15 @@ func isValidLanguage(lang string) bool {
 	return false
 }
 
-func healthCheck(c *Settings) {
-	url := "http://" + c.TranscriptionServer.ExternalHost + ":" +
-		strconv.Itoa(c.TranscriptionServer.HealthCheckPort) + "/health"
-
-	for {
-		log.Printf("[INFO] Performing health check on transcription server: %s", url)
-		if _, err := http.Get(url); err != nil {
-			log.Printf("[ERROR] Transcription server is down (%s): %v. Retrying in 5 seconds...", url, err)
-			time.Sleep(5 * time.Second)
-		} else {
-			log.Printf("[INFO] Transcription server is up")
-			break
+// bbbLanguage returns the BBB code of lang ignoring case, e.g. "pt-BR" for
+// "pt-br".
+func bbbLanguage(lang string) (string, bool) {
+	for _, c := range bbbbot.AllLanguages() {
+		if strings.EqualFold(string(c), lang) {
+			return string(c), true
 		}
 	}
+	return "", false
 }
 
 // -----------------------------------------------------------------------------
@@ -408,64 +929,164 @@ func healthCheck(c *Settings) {
 
 func main() {
 	cli := humacli.New(func(hooks humacli.Hooks, opt *Options) {
-		// ---------------------------------------------------------------------
-		// Initialise settings, external services & state
-		// ---------------------------------------------------------------------
-		var err error
-		log.Printf("[INFO] Loading settings")
-		conf, err = LoadSettings()
+		hooks.OnStart(func() {
+			serve(opt)
+		})
+	})
+	cli.Root().AddCommand(openAPICommand())
+	cli.Root().AddCommand(probeCommand())
+
+	cli.Run()
+}
+
+// serve initialises all services and runs the API server.
+func serve(opt *Options) {
+	// ---------------------------------------------------------------------
+	// Initialise settings, external services & state
+	// ---------------------------------------------------------------------
+	var err error
+	log.Printf("[INFO] Loading settings")
+	conf, err = LoadSettings()
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to load settings: %v", err)
+	}
+
+	servers := make([]*TranscriptionServer, 0, len(conf.TranscriptionServer.Pool))
+	for _, s := range conf.TranscriptionServer.Pool {
+		servers = append(servers, &TranscriptionServer{
+			Host:            s.Host,
+			Port:            s.PortTCP,
+			HealthCheckPort: s.HealthCheckPort,
+		})
+	}
+	transcription_pool = NewTranscriptionPool(servers)
+
+	log.Printf("[INFO] Initializing BBB API client")
+	bbb_api, err = bbbapi.NewRequest(conf.BBB.API.URL, conf.BBB.API.Secret, conf.BBB.API.SHA)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to initialize BBB API client: %v", err)
+	}
+
+	var transcriptionTLS *tls.Config
+	if conf.TranscriptionServer.TLS.Enabled {
+		log.Printf("[INFO] Using TLS for the transcription server connection")
+		transcriptionTLS, err = loadTLSConfig(
+			conf.TranscriptionServer.TLS.CA,
+			conf.TranscriptionServer.TLS.ServerName,
+			conf.TranscriptionServer.TLS.Cert,
+			conf.TranscriptionServer.TLS.Key,
+		)
 		if err != nil {
-			log.Fatalf("[FATAL] Failed to load settings: %v", err)
+			log.Fatalf("[FATAL] Failed to load TLS config: %v", err)
 		}
-		healthCheck(conf)
+	}
 
-		log.Printf("[INFO] Initializing BBB API client")
-		bbb_api, err = bbbapi.NewRequest(conf.BBB.API.URL, conf.BBB.API.Secret, conf.BBB.API.SHA)
-		if err != nil {
-			log.Fatalf("[FATAL] Failed to initialize BBB API client: %v", err)
+	transcriptionKeys := NewKeyVerifier(conf.TranscriptionServer.KeyPins, conf.TranscriptionServer.KnownHostsFile)
+	if len(conf.TranscriptionServer.KeyPins) > 0 {
+		log.Printf("[INFO] Pinning %d transcription server key(s)", len(conf.TranscriptionServer.KeyPins))
+	} else if conf.TranscriptionServer.KnownHostsFile != "" {
+		log.Printf("[INFO] Using known hosts file %s for the transcription server key", conf.TranscriptionServer.KnownHostsFile)
+	}
+
+	log.Printf("[INFO] Loading webhooks")
+	webhooks, err = NewWebhooks(conf.Webhooks.File, conf.Webhooks.Hosts)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to load webhooks: %v", err)
+	}
+
+	// Bots can only join while all required dependencies are up
+	log.Printf("[INFO] Starting dependency watchdog (interval %s)", conf.Watchdog.Interval)
+	watchdog = NewWatchdog(dependencies(), conf.Watchdog.Interval)
+	watchdog.OnDown(func(name string) {
+		if status, ok := watchdog.Status(name); ok {
+			webhooks.EmitDependency(EventDependencyDown, status)
 		}
+	})
+	watchdog.OnRecovered(func(name string) {
+		if status, ok := watchdog.Status(name); ok {
+			webhooks.EmitDependency(EventDependencyRecovered, status)
+		}
+	})
+	go watchdog.Run(make(chan struct{}))
 
-		log.Printf("[INFO] Creating BotManager")
-		BM = NewBotManager(			conf.Bot.Limit,
-			conf.BBB.Client.URL,
-			conf.BBB.Client.WS,
-			conf.BBB.Pad.URL,
-			conf.BBB.Pad.WS,
-			conf.BBB.API.URL,
-			conf.BBB.API.Secret,
-			conf.BBB.WebRTC.WS,
+	if !isValidLanguage(conf.Bot.SourceLanguage) {
+		log.Fatalf("[FATAL] BOT_SOURCE_LANGUAGE %q is not a valid language", conf.Bot.SourceLanguage)
+	}
 
-			conf.TranscriptionServer.ExternalHost,
-			conf.TranscriptionServer.PortTCP,
-			conf.TranscriptionServer.Secret,
+	log.Printf("[INFO] Creating BotManager")
+	BM = NewBotManager(			conf.Bot.Limit,
+		conf.BBB.Client.URL,
+		conf.BBB.Client.WS,
+		conf.BBB.Pad.URL,
+		conf.BBB.Pad.WS,
+		conf.BBB.API.URL,
+		conf.BBB.API.Secret,
+		conf.BBB.WebRTC.WS,
 
-			conf.TranslationServer.URL,
+		transcription_pool,
+		conf.TranscriptionServer.Secret,
+		transcriptionTLS,
+		transcriptionKeys,
 
-			conf.ChangeSet.External,
-			conf.ChangeSet.Port,
-			conf.ChangeSet.Host,
-		)
+		conf.TranslationServer.URL,
 
-		// ---------------------------------------------------------------------
-		// Router & API
-		// ---------------------------------------------------------------------
-		log.Printf("[INFO] Setting up router and API")
-		router := chi.NewMux()
-		api := humachi.New(router, huma.DefaultConfig("BBB Bot API", "1.0.0"))
-		addRoutes(api)
+		conf.ChangeSet.External,
+		conf.ChangeSet.Port,
+		conf.ChangeSet.Host,
 
-		// Serve static assets from ./public
-		router.Mount("/", http.StripPrefix("/", http.FileServer(http.Dir("./public"))))
+		SilenceGateConfig{
+			Enabled:    conf.Audio.SilenceSuppression,
+			MaxPayload: conf.Audio.SilenceMaxPayload,
+			Hangover:   conf.Audio.SilenceHangover,
+		},
 
-		// ---------------------------------------------------------------------
-		// Start server
-		// ---------------------------------------------------------------------
-		hooks.OnStart(func() {
-			addr := fmt.Sprintf(":%d", opt.Port)
-			log.Printf("Server starting on %s ...", addr)
-			log.Fatal(http.ListenAndServe(addr, router))
-		})
-	})
+		webhooks,
+		conf.Lifecycle,
+		conf.ChatCommands,
+		conf.Bot.SourceLanguage,
+		conf.PadBudget,
+		conf.HLS,
+		conf.CaptionSinks,
+	)
 
-	cli.Run()
+	// Bots leave on their own according to their lifecycle policy
+	go BM.RunPolicies(30*time.Second, bbb_api.GetMeetings, make(chan struct{}))
+
+	log.Printf("[INFO] Loading schedules")
+	scheduler, err = NewScheduler(conf.Schedule.File)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to load schedules: %v", err)
+	}
+	go scheduler.Run(make(chan struct{}))
+
+	if conf.BBB.Webhooks.CallbackURL != "" {
+		log.Printf("[INFO] Receiving BBB webhooks at %s", conf.BBB.Webhooks.CallbackURL)
+		bbb_webhooks = NewBBBWebhooks(conf.BBB.Webhooks, conf.BBB.API.URL, conf.BBB.API.Secret)
+		go bbb_webhooks.Run(make(chan struct{}))
+	}
+
+	// ---------------------------------------------------------------------
+	// Router & API
+	// ---------------------------------------------------------------------
+	log.Printf("[INFO] Setting up router and API")
+	router := chi.NewMux()
+	api := humachi.New(router, apiConfig())
+	// Middlewares only apply to operations registered after them
+	if conf.RateLimit.PerMinute > 0 || len(conf.RateLimit.Routes) > 0 {
+		log.Printf("[INFO] Rate limiting API to %d requests per minute and client (%d route overrides)",
+			conf.RateLimit.PerMinute, len(conf.RateLimit.Routes))
+	}
+	api.UseMiddleware(NewRateLimiter(conf.RateLimit.PerMinute, conf.RateLimit.Routes, conf.RateLimit.APIKeys).Middleware(api))
+	quotas = NewQuotas(conf.RateLimit.MaxBots, conf.RateLimit.MaxLanguages)
+	addRoutes(api)
+
+	// Serve static assets from ./public
+	router.Mount("/", http.StripPrefix("/", http.FileServer(http.Dir("./public"))))
+
+	// ---------------------------------------------------------------------
+	// Start server
+	// ---------------------------------------------------------------------
+	addr := fmt.Sprintf(":%d", opt.Port)
+	log.Printf("Server starting on %s ...", addr)
+	log.Fatal(http.ListenAndServe(addr, router))
 }
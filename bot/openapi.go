@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/spf13/cobra"
+)
+
+func apiConfig() huma.Config {
+	return huma.DefaultConfig("BBB Bot API", "1.0.0")
+}
+
+// openAPISpec returns the OpenAPI spec of all routes registered by addRoutes.
+// No services are needed to build it.
+func openAPISpec() ([]byte, error) {
+	api := humachi.New(chi.NewMux(), apiConfig())
+	addRoutes(api)
+	spec, err := json.MarshalIndent(api.OpenAPI(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(spec, '\n'), nil
+}
+
+// openAPICommand prints the spec the Go client in botclient is generated
+// from. With --check it fails if the given file differs from the spec.
+func openAPICommand() *cobra.Command {
+	var check string
+	cmd := &cobra.Command{
+		Use:   "openapi",
+		Short: "Print the OpenAPI spec of the bot API",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			spec, err := openAPISpec()
+			if err != nil {
+				return err
+			}
+			if check == "" {
+				_, err = os.Stdout.Write(spec)
+				return err
+			}
+
+			existing, err := os.ReadFile(check)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(existing, spec) {
+				return fmt.Errorf("%s is out of date, run 'make generate-client'", check)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&check, "check", "", "Compare the spec with this file instead of printing it")
+	return cmd
+}
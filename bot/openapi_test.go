@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// The generated client must be regenerated with the API, see
+// 'make generate-client'.
+func TestOpenAPISpecUpToDate(t *testing.T) {
+	spec, err := openAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing, err := os.ReadFile("botclient/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(existing, spec) {
+		t.Fatal("botclient/openapi.json is out of date, run 'make generate-client'")
+	}
+}
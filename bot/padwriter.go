@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/bigbluebutton-bot/bigbluebutton-bot/pad"
+	gosocketio "github.com/bigbluebutton-bot/golang-socketio"
+)
+
+// How often the text and revision of the pad are fetched from the server
+// again, in case an incremental update got lost
+const padResyncInterval = 2 * time.Minute
+
+// How long a resync waits for the pad text from the server
+const padResyncTimeout = 5 * time.Second
+
+// PadBudget limits how much caption text stays visible in a pad.
+type PadBudget struct {
+	// Trim the pad when it holds more characters, 0 disables trimming
+	MaxChars int
+	// Characters left in the pad after trimming
+	KeepChars int
+}
+
+// PadWriter writes caption text to a pad. Instead of replacing the whole text
+// like pad.SetText, it sends a changeset which only keeps the unchanged
+// beginning and end and replaces what is in between. Changesets come from the
+// changeset service like those of the pad package. If the service fails, the
+// writer builds the changeset itself with spliceChangeset.
+//
+// Changesets are built against the text the writer last sent. When an update
+// failed, someone else changed the pad or padResyncInterval passed, the
+// writer asks the server for the current text and revision first.
+//
+// When the text exceeds the budget, the oldest part is handed to archive and
+// removed from the pad.
+type PadWriter struct {
+	lock     sync.Mutex
+	pad      *pad.Pad
+	lastSync time.Time
+	// Set when an update failed, the next write is a full resync
+	dirty bool
+	// Set by the socket when someone else changed the pad
+	stale atomic.Bool
+
+	// Receives the answer to CLIENT_READY while a resync waits for it
+	varsLock sync.Mutex
+	vars     chan padClientVars
+
+	budget  PadBudget
+	archive func(text string)
+	// Archived beginning of the text, which is no longer written to the pad
+	hidden string
+
+	// Text of finished segments, the current segment and its ID
+	committed string
+	current   string
+	segment   string
+	lastFinal string
+}
+
+func NewPadWriter(p *pad.Pad, budget PadBudget, archive func(text string)) *PadWriter {
+	return &PadWriter{
+		pad:      p,
+		lastSync: time.Now(),
+		budget:   budget,
+		archive:  archive,
+	}
+}
+
+// SetText replaces the caption text with text.
+func (w *PadWriter) SetText(text string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.committed, w.current, w.segment = "", "", ""
+	return w.write(text)
+}
+
+// WriteSegment updates the text of a segment. Finished segments stay in the
+// pad, followed by the segment which is still being transcribed.
+func (w *PadWriter) WriteSegment(id string, text string, final bool) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if id == w.lastFinal {
+		return nil
+	}
+	if id != w.segment {
+		w.committed = joinCaption(w.committed, w.current)
+		w.current = ""
+		w.segment = id
+	}
+	w.current = text
+	if final {
+		w.committed = joinCaption(w.committed, text)
+		w.current, w.segment = "", ""
+		w.lastFinal = id
+	}
+
+	err := w.write(joinCaption(w.committed, w.current))
+	// Archived text is not needed to build the next text
+	if strings.HasPrefix(w.committed, w.hidden) {
+		w.committed = w.committed[len(w.hidden):]
+		w.hidden = ""
+	}
+	return err
+}
+
+// write changes the pad text to text. The lock must be held.
+func (w *PadWriter) write(text string) error {
+	text = w.trim(text)
+
+	// Etherpad text always ends with a newline
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	if w.dirty || w.stale.Load() || time.Since(w.lastSync) >= padResyncInterval {
+		if err := w.resync(); err != nil {
+			w.dirty = true
+			return fmt.Errorf("pad resync failed: %w", err)
+		}
+	}
+
+	changeset, ok := w.changeset(w.pad.Text, text)
+	if !ok {
+		return nil
+	}
+	if err := w.send(changeset); err != nil {
+		w.dirty = true
+		return err
+	}
+	w.pad.Text = text
+	w.pad.BaseRev++
+	return nil
+}
+
+// changeset returns the changeset which turns oldText into newText. ok is
+// false if the texts are equal.
+func (w *PadWriter) changeset(oldText string, newText string) (changeset string, ok bool) {
+	if oldText == newText {
+		return "", false
+	}
+	if w.pad.ChangesetClient != nil {
+		changeset, err := w.pad.ChangesetClient.GenerateChangeset(oldText, newText, w.pad.Attribs)
+		if err == nil && changeset == "" {
+			err = fmt.Errorf("empty changeset")
+		}
+		if err == nil {
+			return changeset, true
+		}
+		log.Printf("[WARN] Changeset service failed, building the changeset locally: %v", err)
+	}
+	return spliceChangeset(oldText, newText)
+}
+
+// trim removes the archived beginning from text and archives more if text is
+// over budget. The lock must be held.
+func (w *PadWriter) trim(text string) string {
+	if w.hidden != "" {
+		if !strings.HasPrefix(text, w.hidden) {
+			// The text was replaced, nothing of it is archived yet
+			w.hidden = ""
+		} else {
+			text = text[len(w.hidden):]
+		}
+	}
+	if w.budget.MaxChars <= 0 || utf8.RuneCountInString(text) <= w.budget.MaxChars {
+		return text
+	}
+
+	cut := trimPoint(text, w.budget.KeepChars)
+	if cut == 0 {
+		return text
+	}
+	if w.archive != nil {
+		w.archive(text[:cut])
+	}
+	w.hidden += text[:cut]
+	return text[cut:]
+}
+
+// trimPoint returns the byte offset text can be cut at to keep about keep
+// characters, without cutting a word in half.
+func trimPoint(text string, keep int) int {
+	runes := utf8.RuneCountInString(text)
+	if keep >= runes {
+		return 0
+	}
+	skip := runes - keep
+	cut := len(text)
+	i := 0
+	for offset := range text {
+		if i == skip {
+			cut = offset
+			break
+		}
+		i++
+	}
+	// Move to the start of the next word
+	if next := strings.IndexAny(text[cut:], " \n"); next >= 0 {
+		cut += next + 1
+	}
+	if cut >= len(text) {
+		return 0
+	}
+	return cut
+}
+
+func joinCaption(a string, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + " " + b
+}
+
+// padClientVars is the text and revision of a pad as sent by the server.
+type padClientVars struct {
+	text    string
+	attribs string
+	rev     int
+}
+
+// padServerMessage is a message of the Etherpad server. CLIENT_VARS is the
+// answer to CLIENT_READY, NEW_CHANGES means another author changed the pad.
+type padServerMessage struct {
+	Type         string `json:"type"`
+	Disconnect   string `json:"disconnect"`
+	AccessStatus string `json:"accessStatus"`
+	Data         struct {
+		Type             string `json:"type"`
+		CollabClientVars struct {
+			InitialAttributedText struct {
+				Text    string `json:"text"`
+				Attribs string `json:"attribs"`
+			} `json:"initialAttributedText"`
+			Rev int `json:"rev"`
+		} `json:"collab_client_vars"`
+	} `json:"data"`
+}
+
+type padClientReady struct {
+	Component string `json:"component"`
+	Type      string `json:"type"`
+	PadID     string `json:"padId"`
+	SessionID string `json:"sessionID"`
+	Token     string `json:"token"`
+	UserInfo  struct {
+		ColorID any `json:"colorId"`
+		Name    any `json:"name"`
+	} `json:"userInfo"`
+}
+
+// resync sends CLIENT_READY again and takes the text and revision from the
+// answer, so the next changeset is built against what the server has. The
+// lock must be held.
+func (w *PadWriter) resync() error {
+	if w.pad.Client == nil || w.pad.AuthorID == "" {
+		return fmt.Errorf("pad is not connected")
+	}
+
+	vars := make(chan padClientVars, 1)
+	w.varsLock.Lock()
+	w.vars = vars
+	w.varsLock.Unlock()
+	defer func() {
+		w.varsLock.Lock()
+		w.vars = nil
+		w.varsLock.Unlock()
+	}()
+
+	// The pad package only handles the first CLIENT_VARS. The handler is set
+	// on every resync, it replaces the one of the pad package once that is
+	// done with connecting.
+	if err := w.pad.Client.On("message", w.onMessage); err != nil {
+		return err
+	}
+	ready := padClientReady{
+		Component: "pad",
+		Type:      "CLIENT_READY",
+		PadID:     w.pad.PadId,
+		SessionID: w.pad.SessionID,
+		Token:     "t." + w.pad.SessionToken,
+	}
+	w.stale.Store(false)
+	if err := w.pad.Client.Emit("message", ready); err != nil {
+		return err
+	}
+
+	select {
+	case state := <-vars:
+		w.pad.Text = state.text
+		w.pad.Attribs = state.attribs
+		w.pad.BaseRev = state.rev
+	case <-time.After(padResyncTimeout):
+		return fmt.Errorf("no answer from the pad server")
+	}
+	w.dirty = false
+	w.lastSync = time.Now()
+	return nil
+}
+
+// onMessage handles the messages of the pad server once a resync took over
+// the socket. Like the pad package, it disconnects when access is denied.
+func (w *PadWriter) onMessage(_ *gosocketio.Channel, msg padServerMessage) {
+	if msg.Disconnect != "" || msg.AccessStatus != "" {
+		w.pad.Disconnect()
+		return
+	}
+	switch {
+	case msg.Type == "CLIENT_VARS":
+		w.varsLock.Lock()
+		defer w.varsLock.Unlock()
+		if w.vars != nil {
+			select {
+			case w.vars <- padClientVars{
+				text:    msg.Data.CollabClientVars.InitialAttributedText.Text,
+				attribs: msg.Data.CollabClientVars.InitialAttributedText.Attribs,
+				rev:     msg.Data.CollabClientVars.Rev,
+			}:
+			default:
+			}
+		}
+	case msg.Data.Type == "NEW_CHANGES":
+		w.stale.Store(true)
+	}
+}
+
+type padUserChanges struct {
+	Type      string             `json:"type"`
+	Component string             `json:"component"`
+	Data      padUserChangesData `json:"data"`
+}
+
+type padUserChangesData struct {
+	Type      string `json:"type"`
+	BaseRev   int    `json:"baseRev"`
+	Changeset string `json:"changeset"`
+	Apool     struct {
+		NumToAttrib map[string][]string `json:"numToAttrib"`
+		NextNum     int                 `json:"nextNum"`
+	} `json:"apool"`
+}
+
+func (w *PadWriter) send(changeset string) error {
+	if w.pad.Client == nil {
+		return fmt.Errorf("pad is not connected")
+	}
+	msg := padUserChanges{
+		Type:      "COLLABROOM",
+		Component: "pad",
+		Data: padUserChangesData{
+			Type:      "USER_CHANGES",
+			BaseRev:   w.pad.BaseRev,
+			Changeset: changeset,
+		},
+	}
+	// Attribute 0 marks inserted text as written by the bot
+	msg.Data.Apool.NumToAttrib = map[string][]string{"0": {"author", w.pad.AuthorID}}
+	msg.Data.Apool.NextNum = 1
+	return w.pad.Client.Emit("message", msg)
+}
+
+// spliceChangeset returns the Etherpad changeset which turns oldText into
+// newText by replacing everything between their common prefix and suffix.
+// ok is false if the texts are equal.
+//
+// Lengths are counted in UTF-16 code units like Etherpad does, e.g.
+// "Hello\n" -> "Hello World\n" is "Z:6>6=5*0+6$ World".
+func spliceChangeset(oldText string, newText string) (changeset string, ok bool) {
+	oldRunes := []rune(oldText)
+	newRunes := []rune(newText)
+
+	prefix := 0
+	for prefix < len(oldRunes) && prefix < len(newRunes) && oldRunes[prefix] == newRunes[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldRunes)-prefix && suffix < len(newRunes)-prefix &&
+		oldRunes[len(oldRunes)-1-suffix] == newRunes[len(newRunes)-1-suffix] {
+		suffix++
+	}
+
+	deleted := oldRunes[prefix : len(oldRunes)-suffix]
+	inserted := newRunes[prefix : len(newRunes)-suffix]
+	if len(deleted) == 0 && len(inserted) == 0 {
+		return "", false
+	}
+
+	oldLen := utf16Len(oldRunes)
+	newLen := utf16Len(newRunes)
+	var b strings.Builder
+	b.WriteString("Z:" + strconv.FormatInt(int64(oldLen), 36))
+	if newLen >= oldLen {
+		b.WriteString(">" + strconv.FormatInt(int64(newLen-oldLen), 36))
+	} else {
+		b.WriteString("<" + strconv.FormatInt(int64(oldLen-newLen), 36))
+	}
+	// Unchanged text at the end needs no operation
+	writeChangesetOps(&b, oldRunes[:prefix], "=", "")
+	writeChangesetOps(&b, deleted, "-", "")
+	writeChangesetOps(&b, inserted, "+", "*0")
+	b.WriteString("$" + string(inserted))
+	return b.String(), true
+}
+
+// writeChangesetOps writes the operations for text. Text up to the last
+// newline needs its own operation with the number of lines.
+func writeChangesetOps(b *strings.Builder, text []rune, op string, attribs string) {
+	if len(text) == 0 {
+		return
+	}
+	last := -1
+	lines := 0
+	for i, r := range text {
+		if r == '\n' {
+			last = i
+			lines++
+		}
+	}
+	if last >= 0 {
+		b.WriteString(attribs + "|" + strconv.FormatInt(int64(lines), 36) + op +
+			strconv.FormatInt(int64(utf16Len(text[:last+1])), 36))
+	}
+	if rest := text[last+1:]; len(rest) > 0 {
+		b.WriteString(attribs + op + strconv.FormatInt(int64(utf16Len(rest)), 36))
+	}
+}
+
+func utf16Len(text []rune) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
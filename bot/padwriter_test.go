@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpliceChangeset(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    string
+		wantOK  bool
+	}{
+		{"append", "Hello\n", "Hello World\n", "Z:6>6=5*0+6$ World", true},
+		{"delete", "Hello World\n", "Hello\n", "Z:c<6=5-6$", true},
+		{"replace", "Hello World\n", "Hello there\n", "Z:c>0=6-5*0+5$there", true},
+		{"equal", "Hello\n", "Hello\n", "", false},
+		{"insert lines", "a\n", "a\nb\nc\n", "Z:2>4|1=2*0|2+4$b\nc\n", true},
+		{"delete lines", "a\nb\nc\n", "a\n", "Z:6<4|1=2|2-4$", true},
+		{"insert after a line", "x\nab\n", "x\naXb\n", "Z:5>1|1=2=1*0+1$X", true},
+		{"non-BMP insert", "a\n", "a😀\n", "Z:2>2=1*0+2$😀", true},
+		{"after non-BMP", "😀\n", "😀!\n", "Z:3>1=2*0+1$!", true},
+		{"non-BMP delete", "a😀b\n", "ab\n", "Z:5<2=1-2$", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := spliceChangeset(tt.oldText, tt.newText)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %q %v, want %q %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWriteChangesetOps(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		op      string
+		attribs string
+		want    string
+	}{
+		{"empty", "", "+", "*0", ""},
+		{"no newline", "abc", "=", "", "=3"},
+		{"ends with newline", "ab\ncd\n", "-", "", "|2-6"},
+		{"text after the last line", "ab\ncd", "+", "*0", "*0|1+3*0+2"},
+		{"non-BMP", "😀\n😀", "+", "", "|1+3+2"},
+		{"base 36", strings.Repeat("x", 40), "=", "", "=14"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeChangesetOps(&b, []rune(tt.text), tt.op, tt.attribs)
+			if got := b.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	bbbapi "github.com/bigbluebutton-bot/bigbluebutton-bot/api"
+	"github.com/bigbluebutton-bot/bigbluebutton-bot/pad"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+	"github.com/spf13/cobra"
+)
+
+// Timeout of every network step of a probe
+const probeTimeout = 10 * time.Second
+
+// prober runs the steps of a probe and prints their results. After the first
+// failed step all following steps are skipped.
+type prober struct {
+	failed bool
+}
+
+// step runs fn and prints its result. fn may return details to print.
+func (p *prober) step(name string, fn func() (string, error)) bool {
+	if p.failed {
+		return false
+	}
+	start := time.Now()
+	detail, err := fn()
+	duration := time.Since(start).Round(time.Microsecond * 100)
+	if err != nil {
+		fmt.Printf("  [FAIL] %s (%s): %v\n", name, duration, err)
+		p.failed = true
+		return false
+	}
+	if detail != "" {
+		fmt.Printf("  [ OK ] %s (%s): %s\n", name, duration, detail)
+	} else {
+		fmt.Printf("  [ OK ] %s (%s)\n", name, duration)
+	}
+	return true
+}
+
+func (p *prober) skip(name string, reason string) {
+	fmt.Printf("  [SKIP] %s: %s\n", name, reason)
+}
+
+// resolve looks up the host of address (host:port).
+func (p *prober) resolve(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	return p.step("resolve "+host, func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(addrs, ", "), nil
+	})
+}
+
+// connect opens and closes a TCP connection to address.
+func (p *prober) connect(address string) bool {
+	return p.step("connect to "+address, func() (string, error) {
+		conn, err := net.DialTimeout("tcp", address, probeTimeout)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		return "", nil
+	})
+}
+
+type probe struct {
+	name  string
+	short string
+	run   func(p *prober, cfg *Settings)
+}
+
+var probes = []probe{
+	{"bbb", "Signed BBB API call", probeBBB},
+	{"transcription", "Key exchange, token and UDP delivery with every transcription server", probeTranscription},
+	{"translation", "Language list and sample translation", probeTranslation},
+	{"changeset", "Changeset generation over gRPC", probeChangeset},
+}
+
+// probeCommand checks each dependency step by step and prints which step
+// failed and why. Without a subcommand all dependencies are probed.
+func probeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "probe",
+		Short: "Check the connection to each dependency step by step",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runProbes(probes...)
+		},
+	}
+	for _, pr := range probes {
+		pr := pr
+		cmd.AddCommand(&cobra.Command{
+			Use:   pr.name,
+			Short: pr.short,
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				runProbes(pr)
+			},
+		})
+	}
+	return cmd
+}
+
+// runProbes loads the settings and runs the probes. It exits with status 1 if
+// a step failed.
+func runProbes(list ...probe) {
+	cfg, err := LoadSettings()
+	if err != nil {
+		fmt.Printf("[FAIL] load settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, pr := range list {
+		fmt.Printf("probe %s\n", pr.name)
+		p := &prober{}
+		pr.run(p, cfg)
+		failed = failed || p.failed
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func probeBBB(p *prober, cfg *Settings) {
+	apiURL, err := url.Parse(cfg.BBB.API.URL)
+	if !p.step("parse BBB_API_URL", func() (string, error) {
+		if err != nil {
+			return "", err
+		}
+		if apiURL.Host == "" {
+			return "", fmt.Errorf("%q has no host", cfg.BBB.API.URL)
+		}
+		return apiURL.String(), nil
+	}) {
+		return
+	}
+	address := apiURL.Host
+	if apiURL.Port() == "" {
+		if apiURL.Scheme == "https" {
+			address += ":443"
+		} else {
+			address += ":80"
+		}
+	}
+	if !p.resolve(address) || !p.connect(address) {
+		return
+	}
+
+	client := &http.Client{Timeout: probeTimeout}
+	p.step("unsigned API call", func() (string, error) {
+		resp, err := client.Get(cfg.BBB.API.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		var result struct {
+			ReturnCode string `xml:"returncode"`
+			Version    string `xml:"version"`
+		}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return "", fmt.Errorf("status %d, response is not BBB API XML: %w", resp.StatusCode, err)
+		}
+		if result.ReturnCode != "SUCCESS" {
+			return "", fmt.Errorf("returncode %q", result.ReturnCode)
+		}
+		return "BBB API version " + result.Version, nil
+	})
+
+	var api *bbbapi.ApiRequest
+	p.step("create API client", func() (string, error) {
+		api, err = bbbapi.NewRequest(cfg.BBB.API.URL, cfg.BBB.API.Secret, cfg.BBB.API.SHA)
+		return string(cfg.BBB.API.SHA), err
+	})
+	p.step("checksum signed getMeetings", func() (string, error) {
+		meetings, err := api.GetMeetings()
+		if err != nil {
+			return "", fmt.Errorf("%w (check BBB_API_SECRET and BBB_API_SHA)", err)
+		}
+		return fmt.Sprintf("%d running meeting(s)", len(meetings)), nil
+	})
+}
+
+func probeTranscription(p *prober, cfg *Settings) {
+	var tlsConfig *tls.Config
+	if cfg.TranscriptionServer.TLS.Enabled {
+		p.step("load TLS config", func() (string, error) {
+			var err error
+			tlsConfig, err = loadTLSConfig(
+				cfg.TranscriptionServer.TLS.CA,
+				cfg.TranscriptionServer.TLS.ServerName,
+				cfg.TranscriptionServer.TLS.Cert,
+				cfg.TranscriptionServer.TLS.Key,
+			)
+			return "", err
+		})
+	}
+	if p.failed {
+		return
+	}
+	keys := NewKeyVerifier(cfg.TranscriptionServer.KeyPins, cfg.TranscriptionServer.KnownHostsFile)
+
+	// A broken server must not hide the state of the others
+	for _, server := range cfg.TranscriptionServer.Pool {
+		fmt.Printf(" server %s:%d\n", server.Host, server.PortTCP)
+		sp := &prober{}
+		probeTranscriptionServer(sp, server, cfg.TranscriptionServer.Secret, tlsConfig, keys)
+		p.failed = p.failed || sp.failed
+	}
+}
+
+func probeTranscriptionServer(p *prober, server TranscriptionServerConfig, secret string, tlsConfig *tls.Config, keys *KeyVerifier) {
+	address := net.JoinHostPort(server.Host, strconv.Itoa(server.PortTCP))
+	if !p.resolve(address) {
+		return
+	}
+
+	if server.HealthCheckPort > 0 {
+		health := &TranscriptionServer{Host: server.Host, HealthCheckPort: server.HealthCheckPort}
+		p.step("health check "+health.healthURL(), func() (string, error) {
+			client := &http.Client{Timeout: probeTimeout}
+			resp, err := client.Get(health.healthURL())
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			io.Copy(io.Discard, resp.Body)
+			if resp.StatusCode >= http.StatusInternalServerError {
+				return "", fmt.Errorf("status %d", resp.StatusCode)
+			}
+			return fmt.Sprintf("status %d", resp.StatusCode), nil
+		})
+	} else {
+		p.skip("health check", "no health check port configured")
+	}
+
+	c := NewTCPclient(address, true)
+	c.Secret_token = secret
+	c.TLSConfig = tlsConfig
+	c.KeyVerifier = keys
+
+	connect := "connect"
+	if tlsConfig != nil {
+		connect = "TLS handshake"
+	}
+	if !p.step(connect, func() (string, error) {
+		dialer := &net.Dialer{Timeout: probeTimeout}
+		var err error
+		if tlsConfig != nil {
+			c.connection, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+		} else {
+			c.connection, err = dialer.Dial("tcp", address)
+		}
+		return "", err
+	}) {
+		return
+	}
+	defer c.connection.Close()
+	c.connection.SetDeadline(time.Now().Add(probeTimeout))
+
+	if tlsConfig != nil {
+		c.cipherMode = CipherTLS
+		p.step("verify TLS key", func() (string, error) {
+			if keys == nil {
+				return "not pinned", nil
+			}
+			return "", c.verifyTLSKey()
+		})
+	} else {
+		p.step("RSA/AES key exchange", func() (string, error) {
+			if err := c.exchangeKeys(); err != nil {
+				return "", err
+			}
+			return "cipher " + string(c.cipherMode), nil
+		})
+	}
+
+	// read returns the next message as plain text
+	read := func() (string, error) {
+		message, err := c.readMessage()
+		if err != nil {
+			if err == io.EOF {
+				return "", fmt.Errorf("server closed the connection")
+			}
+			return "", err
+		}
+		message, err = c.decryptLegacy(message)
+		return string(message), err
+	}
+
+	p.step("wait for OK", func() (string, error) {
+		message, err := read()
+		if err != nil {
+			return "", err
+		}
+		if message != "OK" {
+			return "", fmt.Errorf("expected OK, got %q", message)
+		}
+		return "", nil
+	})
+	p.step("send token", func() (string, error) {
+		return "", c.Send(secret)
+	})
+
+	var udpAddr struct {
+		Msg struct {
+			UDP struct {
+				Host       string `json:"host"`
+				Port       int    `json:"port"`
+				Encryption bool   `json:"encryption"`
+				Sequence   bool   `json:"sequence"`
+			} `json:"udp"`
+			Protocol int `json:"protocol"`
+		} `json:"msg"`
+	}
+	p.step("wait for UDP address", func() (string, error) {
+		message, err := read()
+		if err != nil {
+			return "", fmt.Errorf("%w (was the token rejected? check TRANSCRIPTION_SERVER_SECRET)", err)
+		}
+		if err := json.Unmarshal([]byte(message), &udpAddr); err != nil {
+			return "", fmt.Errorf("unexpected message %q", message)
+		}
+		return fmt.Sprintf("%s:%d, protocol %d", udpAddr.Msg.UDP.Host, udpAddr.Msg.UDP.Port, udpAddr.Msg.Protocol), nil
+	})
+
+	capabilities := Capabilities{Features: []string{}}
+	if !p.failed && udpAddr.Msg.Protocol >= protocolVersion {
+		p.step("negotiate capabilities", func() (string, error) {
+			hello, err := json.Marshal(helloMessage{
+				Type: "hello",
+				Msg:  helloBody{Version: clientVersion, Protocol: protocolVersion, Features: clientFeatures},
+			})
+			if err != nil {
+				return "", err
+			}
+			if err := c.Send(string(hello)); err != nil {
+				return "", err
+			}
+			for {
+				message, err := read()
+				if err != nil {
+					return "", err
+				}
+				var reply helloMessage
+				if json.Unmarshal([]byte(message), &reply) == nil && reply.Type == "hello" {
+					capabilities.Features = reply.Msg.Features
+					return fmt.Sprintf("server %s, features %s", reply.Msg.Version, strings.Join(reply.Msg.Features, ", ")), nil
+				}
+			}
+		})
+	} else if !p.failed {
+		p.skip("negotiate capabilities", "server uses protocol 1")
+	}
+
+	// Like the bot, send the stream through the negotiated cipher
+	sequenced := udpAddr.Msg.UDP.Sequence || capabilities.Has(FeatureUDPSequence)
+	var udp *UDPclient
+	if !p.step("open UDP socket", func() (string, error) {
+		udpCipher, err := c.NewUDPCipher()
+		if err != nil {
+			return "", err
+		}
+		udp = NewUDPclient(net.JoinHostPort(udpAddr.Msg.UDP.Host, strconv.Itoa(udpAddr.Msg.UDP.Port)),
+			udpAddr.Msg.UDP.Encryption, sequenced, c.aesKey, c.aesIV, udpCipher)
+		return "", udp.Connect()
+	}) {
+		return
+	}
+	defer udp.Close()
+
+	// UDP has no answer, only the loss reports tell if the server got the
+	// packets
+	if !sequenced || !capabilities.Has(FeatureLossReport) {
+		p.skip("UDP delivery", "the server sends no loss reports, delivery can not be checked")
+		return
+	}
+	p.step("UDP delivery", func() (string, error) {
+		// The Ogg headers of an Opus stream, as the bot starts its stream
+		if _, err := oggwriter.NewWith(udp, 48000, 2); err != nil {
+			return "", err
+		}
+		sent := udp.Stats().Sent
+		c.connection.SetDeadline(time.Now().Add(probeTimeout))
+		reported := false
+		for {
+			message, err := read()
+			if err != nil && reported {
+				return "", fmt.Errorf("server received none of %d packets (is UDP port %d blocked?)", sent, udpAddr.Msg.UDP.Port)
+			}
+			if err != nil {
+				return "", fmt.Errorf("no loss report: %w", err)
+			}
+			var report struct {
+				Type string     `json:"type"`
+				Msg  LossReport `json:"msg"`
+			}
+			if json.Unmarshal([]byte(message), &report) != nil || report.Type != "loss_report" {
+				continue
+			}
+			// Reports sent before the packets arrived count nothing yet
+			if report.Msg.Received == 0 {
+				reported = true
+				continue
+			}
+			return fmt.Sprintf("server received %d of %d packets", report.Msg.Received, sent), nil
+		}
+	})
+}
+
+func probeTranslation(p *prober, cfg *Settings) {
+	translateURL, err := url.Parse(cfg.TranslationServer.URL)
+	if !p.step("parse TRANSLATION_SERVER_URL", func() (string, error) {
+		if err != nil {
+			return "", err
+		}
+		if translateURL.Host == "" {
+			return "", fmt.Errorf("%q has no host", cfg.TranslationServer.URL)
+		}
+		return translateURL.String(), nil
+	}) {
+		return
+	}
+	address := translateURL.Host
+	if translateURL.Port() == "" {
+		if translateURL.Scheme == "https" {
+			address += ":443"
+		} else {
+			address += ":80"
+		}
+	}
+	if !p.resolve(address) || !p.connect(address) {
+		return
+	}
+
+	source := ConvertBBBToLibretranslate(cfg.Bot.SourceLanguage)
+	p.step("list languages", func() (string, error) {
+		client := &http.Client{Timeout: probeTimeout}
+		resp, err := client.Get(strings.TrimSuffix(cfg.TranslationServer.URL, "/translate") + "/languages")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("status %d", resp.StatusCode)
+		}
+		languages := make([]struct {
+			Code string `json:"code"`
+		}, 0)
+		if err := json.NewDecoder(resp.Body).Decode(&languages); err != nil {
+			return "", err
+		}
+		for _, l := range languages {
+			if l.Code == source {
+				return fmt.Sprintf("%d languages", len(languages)), nil
+			}
+		}
+		return "", fmt.Errorf("source language %q is not installed (%d languages)", source, len(languages))
+	})
+
+	target := "de"
+	if cfg.Bot.SourceLanguage == target {
+		target = "en"
+	}
+	p.step(fmt.Sprintf("translate %s -> %s", cfg.Bot.SourceLanguage, target), func() (string, error) {
+		text, err := translate(cfg.TranslationServer.URL, "Hello, this is a test.", cfg.Bot.SourceLanguage, target)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Quote(text), nil
+	})
+}
+
+func probeChangeset(p *prober, cfg *Settings) {
+	if !cfg.ChangeSet.External {
+		p.skip("changeset service", "CHANGESET_EXTERNAL is false, every pad starts its own changeset server")
+		return
+	}
+	address := net.JoinHostPort(cfg.ChangeSet.Host, strconv.Itoa(cfg.ChangeSet.Port))
+	if !p.resolve(address) || !p.connect(address) {
+		return
+	}
+
+	client := pad.NewChangesetClient(cfg.ChangeSet.Host, strconv.Itoa(cfg.ChangeSet.Port))
+	if !p.step("gRPC connect", func() (string, error) {
+		return "", client.Connect()
+	}) {
+		return
+	}
+	defer client.Close()
+
+	p.step("generate changeset", func() (string, error) {
+		changeset, err := client.GenerateChangeset("\n", "ping\n", "|1+1")
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(changeset, "Z:") {
+			return "", fmt.Errorf("unexpected changeset %q", changeset)
+		}
+		return changeset, nil
+	})
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Header clients can use to identify themselves with a key from
+// RATE_LIMIT_API_KEYS. Without a known key, clients are identified by their IP
+// address, so random keys can't be used to get fresh limits and quotas.
+const apiKeyHeader = "X-API-Key"
+
+// Buckets which were not used for this long are removed
+const bucketIdleTimeout = 10 * time.Minute
+
+// Quotas free up when bots leave, which can not be predicted. Clients are
+// asked to retry after this many seconds.
+const quotaRetryAfter = 60
+
+// Operations with this metadata key are never rate limited, e.g. callbacks of
+// the BBB server.
+const rateLimitExempt = "rate-limit-exempt"
+
+type clientContextKey struct{}
+
+// clientFromContext returns the client which made the API request.
+func clientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(clientContextKey{}).(string)
+	return client
+}
+
+func (l *RateLimiter) clientID(ctx huma.Context) string {
+	if key := ctx.Header(apiKeyHeader); key != "" && l.apiKeys[key] {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(ctx.RemoteAddr())
+	if err != nil {
+		host = ctx.RemoteAddr()
+	}
+	return "ip:" + host
+}
+
+// tokenBucket allows limit requests per minute with bursts of up to limit.
+type tokenBucket struct {
+	tokens   float64
+	limit    float64
+	lastSeen time.Time
+}
+
+// take removes one token. If the bucket is empty, it returns how long to wait
+// for the next one.
+func (b *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	rate := b.limit / 60
+	b.tokens = math.Min(b.limit, b.tokens+now.Sub(b.lastSeen).Seconds()*rate)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// RateLimiter limits the requests per minute of every client, per operation.
+type RateLimiter struct {
+	lock      sync.Mutex
+	perMinute int
+	routes    map[string]int
+	apiKeys   map[string]bool
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a limiter with a default limit for all operations
+// and overrides per operation ID. A limit of 0 disables limiting. Clients
+// sending one of apiKeys are limited per key instead of per IP address.
+func NewRateLimiter(perMinute int, routes map[string]int, apiKeys []string) *RateLimiter {
+	known := make(map[string]bool, len(apiKeys))
+	for _, key := range apiKeys {
+		known[key] = true
+	}
+	return &RateLimiter{
+		perMinute: perMinute,
+		routes:    routes,
+		apiKeys:   known,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *RateLimiter) limit(operation string) int {
+	if limit, ok := l.routes[operation]; ok {
+		return limit
+	}
+	return l.perMinute
+}
+
+// Allow takes a token from the bucket of the client and operation. If the
+// limit is reached, it returns how long the client has to wait.
+func (l *RateLimiter) Allow(client string, operation string) (time.Duration, bool) {
+	limit := l.limit(operation)
+	if limit <= 0 {
+		return 0, true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > bucketIdleTimeout {
+		for key, bucket := range l.buckets {
+			if now.Sub(bucket.lastSeen) > bucketIdleTimeout {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	key := client + " " + operation
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit), limit: float64(limit), lastSeen: now}
+		l.buckets[key] = bucket
+	}
+	return bucket.take(now)
+}
+
+// Middleware identifies the client of every request and rejects it with 429
+// if the client exceeded the limit. System endpoints like the health probes
+// and operations marked with rateLimitExempt are never limited.
+func (l *RateLimiter) Middleware(api huma.API) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		client := l.clientID(ctx)
+		ctx = huma.WithValue(ctx, clientContextKey{}, client)
+
+		operation := ctx.Operation()
+		if exempt, _ := operation.Metadata[rateLimitExempt].(bool); exempt {
+			next(ctx)
+			return
+		}
+		for _, tag := range operation.Tags {
+			if tag == "System" {
+				next(ctx)
+				return
+			}
+		}
+
+		if wait, ok := l.Allow(client, operation.OperationID); !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			log.Printf("[WARN] Rate limit of %s exceeded for %s", operation.OperationID, ctx.RemoteAddr())
+			ctx.SetHeader("Retry-After", strconv.Itoa(seconds))
+			huma.WriteErr(api, ctx, http.StatusTooManyRequests,
+				fmt.Sprintf("Rate limit exceeded, retry in %d seconds", seconds))
+			return
+		}
+		next(ctx)
+	}
+}
+
+// Quotas limits how many bots and translated languages each client can have.
+// A limit of 0 means unlimited.
+type Quotas struct {
+	lock         sync.Mutex
+	MaxBots      int
+	MaxLanguages int
+	// Bot ID -> client which created the bot
+	owners map[string]string
+	// Client -> bots reserved by ReserveBot which have no owner yet
+	reserved map[string]int
+}
+
+func NewQuotas(maxBots int, maxLanguages int) *Quotas {
+	return &Quotas{
+		MaxBots:      maxBots,
+		MaxLanguages: maxLanguages,
+		owners:       make(map[string]string),
+		reserved:     make(map[string]int),
+	}
+}
+
+// SetOwner records the client which created the bot and turns a slot
+// reserved by ReserveBot into the bot.
+func (q *Quotas) SetOwner(botID string, client string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.owners[botID] = client
+	q.release(client)
+}
+
+// ReserveBot checks the bot quota of the client and reserves a slot in the
+// same step, so concurrent joins can't exceed it. The slot is taken by
+// SetOwner or given back with ReleaseBot.
+func (q *Quotas) ReserveBot(client string) error {
+	if q.MaxBots <= 0 {
+		return nil
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.ownedBotsLocked(client))+q.reserved[client] >= q.MaxBots {
+		return quotaError(fmt.Sprintf("Quota exceeded: max %d bots per client", q.MaxBots))
+	}
+	q.reserved[client]++
+	return nil
+}
+
+// ReleaseBot gives back a slot reserved by ReserveBot which is not used.
+func (q *Quotas) ReleaseBot(client string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.release(client)
+}
+
+// release gives back a reserved slot of the client. The lock must be held.
+func (q *Quotas) release(client string) {
+	if q.reserved[client] <= 1 {
+		delete(q.reserved, client)
+		return
+	}
+	q.reserved[client]--
+}
+
+// ownedBots returns the running bots of the client and forgets owners of
+// bots which are gone.
+func (q *Quotas) ownedBots(client string) []*Bot {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.ownedBotsLocked(client)
+}
+
+// ownedBotsLocked is ownedBots with the lock already held.
+func (q *Quotas) ownedBotsLocked(client string) []*Bot {
+	running := make(map[string]*Bot)
+	for _, bot := range BM.botList() {
+		running[bot.ID] = bot
+	}
+	owned := make([]*Bot, 0)
+	for botID, owner := range q.owners {
+		bot, ok := running[botID]
+		if !ok {
+			delete(q.owners, botID)
+			continue
+		}
+		if owner == client {
+			owned = append(owned, bot)
+		}
+	}
+	return owned
+}
+
+// CheckLanguages returns an error if the bots of the client can not translate
+// another language.
+func (q *Quotas) CheckLanguages(client string) error {
+	if q.MaxLanguages <= 0 {
+		return nil
+	}
+	languages := 0
+	for _, bot := range q.ownedBots(client) {
+		// The source language is no translation
+		for _, lang := range bot.GetAllActiveTranslations() {
+			if lang != bot.SourceLanguage {
+				languages++
+			}
+		}
+	}
+	if languages >= q.MaxLanguages {
+		return quotaError(fmt.Sprintf("Quota exceeded: max %d languages per client", q.MaxLanguages))
+	}
+	return nil
+}
+
+func quotaError(msg string) error {
+	return huma.ErrorWithHeaders(huma.NewError(http.StatusTooManyRequests, msg), http.Header{
+		"Retry-After": {strconv.Itoa(quotaRetryAfter)},
+	})
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How often the scheduler looks for meetings to join or leave
+const schedulerInterval = 30 * time.Second
+
+// Recurring schedules can not run longer than a day
+const maxScheduleDuration = 24 * time.Hour
+
+// ScheduleSpec describes when and where a bot should join.
+type ScheduleSpec struct {
+	MeetingID       string    `json:"meeting_id,omitempty" doc:"Meeting ID to join"`
+	NamePattern     string    `json:"name_pattern,omitempty" doc:"Join the first meeting whose name matches this glob pattern, e.g. 'Lecture *'"`
+	Start           time.Time `json:"start,omitempty" doc:"Start of a one-time window"`
+	End             time.Time `json:"end,omitempty" doc:"End of a one-time window"`
+	Cron            string    `json:"cron,omitempty" doc:"Start times of a recurring window, e.g. '0 10 * * 1-5'" example:"0 10 * * 1-5"`
+	DurationMinutes int       `json:"duration_minutes,omitempty" doc:"Length of a recurring window in minutes"`
+	Task            string    `json:"task" enum:"transcribe,translate" doc:"Task type"`
+	Languages       []string  `json:"languages,omitempty" doc:"Languages to translate to"`
+}
+
+// Schedule is a stored ScheduleSpec together with its state.
+type Schedule struct {
+	ID string `json:"id"`
+	ScheduleSpec
+	// Bot started by this schedule
+	BotID string `json:"bot_id,omitempty"`
+	// Start of the last window a bot was started in. A bot is only started once
+	// per window, even if it leaves early.
+	LastWindow time.Time `json:"last_window,omitempty"`
+
+	cron *CronSpec
+}
+
+// Validate checks the spec and parses the cron expression.
+func (s *Schedule) Validate() error {
+	if (s.MeetingID == "") == (s.NamePattern == "") {
+		return fmt.Errorf("exactly one of meeting_id and name_pattern is required")
+	}
+	if s.NamePattern != "" {
+		if _, err := path.Match(s.NamePattern, ""); err != nil {
+			return fmt.Errorf("invalid name_pattern: %w", err)
+		}
+	}
+
+	if s.Cron != "" {
+		if !s.Start.IsZero() || !s.End.IsZero() {
+			return fmt.Errorf("start and end can not be used with cron")
+		}
+		duration := time.Duration(s.DurationMinutes) * time.Minute
+		if duration <= 0 || duration > maxScheduleDuration {
+			return fmt.Errorf("duration_minutes must be between 1 and %d", int(maxScheduleDuration.Minutes()))
+		}
+		cron, err := ParseCron(s.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron: %w", err)
+		}
+		s.cron = cron
+	} else {
+		if s.Start.IsZero() || s.End.IsZero() {
+			return fmt.Errorf("either start and end or cron and duration_minutes are required")
+		}
+		if !s.End.After(s.Start) {
+			return fmt.Errorf("end must be after start")
+		}
+	}
+
+	if len(s.Languages) > 0 && s.Task != "translate" {
+		return fmt.Errorf("languages require the translate task")
+	}
+	return nil
+}
+
+// Window returns the window which contains now.
+func (s *Schedule) Window(now time.Time) (time.Time, time.Time, bool) {
+	if s.cron != nil {
+		duration := time.Duration(s.DurationMinutes) * time.Minute
+		start, ok := s.cron.Latest(now, duration)
+		return start, start.Add(duration), ok
+	}
+	return s.Start, s.End, !now.Before(s.Start) && now.Before(s.End)
+}
+
+// Expired reports whether a one-time schedule is over.
+func (s *Schedule) Expired(now time.Time) bool {
+	return s.cron == nil && !now.Before(s.End)
+}
+
+func (s *Schedule) matches(meeting string, name string) bool {
+	if s.MeetingID != "" {
+		return s.MeetingID == meeting
+	}
+	ok, _ := path.Match(s.NamePattern, name)
+	return ok
+}
+
+// Scheduler joins bots to planned meetings. Schedules are stored in a JSON
+// file so they survive restarts.
+type Scheduler struct {
+	lock      sync.Mutex
+	file      string
+	schedules map[string]*Schedule
+	trigger   chan struct{}
+}
+
+// NewScheduler loads the schedules from file. An empty file name disables
+// persistence.
+func NewScheduler(file string) (*Scheduler, error) {
+	s := &Scheduler{
+		file:      file,
+		schedules: make(map[string]*Schedule),
+		trigger:   make(chan struct{}, 1),
+	}
+	if file == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read schedule file: %w", err)
+	}
+	schedules := make([]*Schedule, 0)
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("could not parse schedule file: %w", err)
+	}
+	for _, schedule := range schedules {
+		if err := schedule.Validate(); err != nil {
+			log.Printf("[WARN] Skipping invalid schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		s.schedules[schedule.ID] = schedule
+	}
+	return s, nil
+}
+
+// save writes all schedules to the file. The lock must be held.
+func (s *Scheduler) save() {
+	if s.file == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode schedules: %v", err)
+		return
+	}
+	// Write to a temporary file first, so a crash can not corrupt the file
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("[ERROR] Failed to write schedule file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		log.Printf("[ERROR] Failed to write schedule file: %v", err)
+	}
+}
+
+// list returns all schedules sorted by ID. The lock must be held.
+func (s *Scheduler) list() []Schedule {
+	schedules := make([]Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, *schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules
+}
+
+func (s *Scheduler) Schedules() []Schedule {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.list()
+}
+
+func (s *Scheduler) Schedule(id string) (Schedule, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if schedule, ok := s.schedules[id]; ok {
+		return *schedule, true
+	}
+	return Schedule{}, false
+}
+
+func (s *Scheduler) Add(spec ScheduleSpec) (Schedule, error) {
+	schedule := &Schedule{
+		ID:           uuid.New().String(),
+		ScheduleSpec: spec,
+	}
+	if err := schedule.Validate(); err != nil {
+		return Schedule{}, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.schedules[schedule.ID] = schedule
+	s.save()
+	return *schedule, nil
+}
+
+// Remove deletes the schedule. A bot started by it keeps running.
+func (s *Scheduler) Remove(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return false
+	}
+	delete(s.schedules, id)
+	s.save()
+	return true
+}
+
+// Run checks all schedules every schedulerInterval, or when triggered, until
+// stop is closed.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for {
+		s.tick(time.Now())
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-s.trigger:
+		}
+	}
+}
+
+// Trigger checks all schedules right away, e.g. when a new meeting was
+// created.
+func (s *Scheduler) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// scheduledJoin is a meeting a schedule wants to join in its window.
+type scheduledJoin struct {
+	schedule  Schedule
+	start     time.Time
+	end       time.Time
+	meetingID string
+}
+
+// tick joins and removes the bots of all schedules. The lock is only held
+// while deciding what to do, the BBB API calls happen without it.
+func (s *Scheduler) tick(now time.Time) {
+	leave, joins := s.plan(now)
+
+	for _, botID := range leave {
+		BM.RemoveBot(botID, LeaveScheduleEnded)
+	}
+	if len(joins) == 0 {
+		return
+	}
+
+	// Wait for the meeting to appear
+	meetings, err := runningMeetings()
+	if err != nil {
+		log.Printf("[ERROR] Scheduler failed to fetch meetings: %v", err)
+		return
+	}
+	for i := range joins {
+		join := &joins[i]
+		for id, name := range meetings {
+			if join.schedule.matches(id, name) {
+				join.meetingID = id
+				break
+			}
+		}
+		if join.meetingID == "" {
+			continue
+		}
+
+		bot, err := joinMeeting(join.meetingID, join.schedule.Task, join.schedule.Languages)
+		if err != nil {
+			log.Printf("[ERROR] Schedule %s failed to join meeting %s: %v", join.schedule.ID, join.meetingID, err)
+			continue
+		}
+		log.Printf("[INFO] Schedule %s joined meeting %s until %s", join.schedule.ID, join.meetingID, join.end.Format(time.RFC3339))
+		s.joined(join.schedule.ID, bot.ID, join.start)
+	}
+}
+
+// plan returns the bots to remove and the schedules which should join a
+// meeting. Changed schedules are saved.
+func (s *Scheduler) plan(now time.Time) ([]string, []scheduledJoin) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	leave := make([]string, 0)
+	joins := make([]scheduledJoin, 0)
+	changed := false
+	for id, schedule := range s.schedules {
+		start, end, active := schedule.Window(now)
+
+		// Leave at the end of the window
+		if schedule.BotID != "" {
+			_, ok := BM.Bot(schedule.BotID)
+			if ok && (!active || !start.Equal(schedule.LastWindow)) {
+				log.Printf("[INFO] Schedule %s ended, removing bot %s", id, schedule.BotID)
+				leave = append(leave, schedule.BotID)
+				ok = false
+			}
+			if !ok {
+				schedule.BotID = ""
+				changed = true
+			}
+		}
+
+		if schedule.Expired(now) {
+			log.Printf("[INFO] Schedule %s expired", id)
+			delete(s.schedules, id)
+			changed = true
+			continue
+		}
+
+		if !active || schedule.BotID != "" || start.Equal(schedule.LastWindow) {
+			continue
+		}
+		joins = append(joins, scheduledJoin{schedule: *schedule, start: start, end: end})
+	}
+
+	if changed {
+		s.save()
+	}
+	return leave, joins
+}
+
+// joined records the bot a schedule started in the window beginning at start.
+// If the schedule was removed meanwhile, the bot keeps running like the bots
+// of removed schedules do.
+func (s *Scheduler) joined(id string, botID string, start time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return
+	}
+	schedule.BotID = botID
+	schedule.LastWindow = start
+	s.save()
+}
+
+// runningMeetings returns the name of every meeting by ID.
+func runningMeetings() (map[string]string, error) {
+	meetings, err := bbb_api.GetMeetings()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(meetings))
+	for id, meeting := range meetings {
+		names[id] = meeting.MeetingName
+	}
+	return names, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// Clock rate of the Opus RTP stream
+const opusClockRate = 48000
+
+// SilenceGateConfig configures the silence suppression of a bot.
+type SilenceGateConfig struct {
+	Enabled bool
+	// Opus payloads up to this size (in bytes) are treated as silence. DTX
+	// frames are 1-2 bytes, silent CELT/SILK frames usually stay below 10.
+	MaxPayload int
+	// How long audio is still forwarded after the last speech frame.
+	Hangover time.Duration
+}
+
+// SilenceStats holds the counters of a SilenceGate.
+type SilenceStats struct {
+	Enabled      bool   `json:"enabled"`
+	Speaking     bool   `json:"speaking"`
+	Forwarded    uint64 `json:"forwarded"`
+	Suppressed   uint64 `json:"suppressed"`
+	SuppressedMs uint64 `json:"suppressed_ms"`
+}
+
+// SilenceGate decides per Opus packet whether it is forwarded to the
+// transcription server. Silent packets are dropped once the hangover period
+// after the last speech frame has passed. The first speech frame opens the
+// gate again immediately.
+type SilenceGate struct {
+	lock   sync.Mutex
+	config SilenceGateConfig
+
+	hangoverSamples uint32
+	speaking        bool
+	lastSpeechTS    uint32
+	lastTS          uint32
+	started         bool
+	// Wall clock time of the last speech frame, tracked even if disabled
+	lastVoice time.Time
+
+	stats SilenceStats
+}
+
+func NewSilenceGate(config SilenceGateConfig) *SilenceGate {
+	return &SilenceGate{
+		config:          config,
+		hangoverSamples: uint32(config.Hangover.Seconds() * opusClockRate),
+		lastVoice:       time.Now(),
+		stats: SilenceStats{
+			Enabled: config.Enabled,
+		},
+	}
+}
+
+// Reset clears the state and counters for a new audio stream, as if the gate
+// was just created.
+func (g *SilenceGate) Reset() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.speaking = false
+	g.lastSpeechTS = 0
+	g.lastTS = 0
+	g.started = false
+	g.lastVoice = time.Now()
+	g.stats = SilenceStats{Enabled: g.config.Enabled}
+}
+
+// Allow reports whether the packet should be forwarded.
+func (g *SilenceGate) Allow(packet *rtp.Packet) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	silence := g.isSilence(packet)
+	if !silence {
+		g.lastVoice = time.Now()
+	}
+
+	if !g.config.Enabled {
+		g.stats.Forwarded++
+		return true
+	}
+
+	elapsed := uint32(0)
+	if g.started {
+		elapsed = packet.Timestamp - g.lastTS
+	}
+	g.started = true
+	g.lastTS = packet.Timestamp
+
+	if !silence {
+		g.speaking = true
+		g.lastSpeechTS = packet.Timestamp
+	} else if g.speaking && packet.Timestamp-g.lastSpeechTS > g.hangoverSamples {
+		g.speaking = false
+	}
+	g.stats.Speaking = g.speaking
+
+	if !g.speaking {
+		g.stats.Suppressed++
+		g.stats.SuppressedMs += uint64(elapsed) * 1000 / opusClockRate
+		return false
+	}
+
+	g.stats.Forwarded++
+	return true
+}
+
+// Stats returns a copy of the current counters.
+func (g *SilenceGate) Stats() SilenceStats {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.stats
+}
+
+// LastVoice returns when the last speech frame was seen, or when the gate was
+// created if there was none yet.
+func (g *SilenceGate) LastVoice() time.Time {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.lastVoice
+}
+
+// isSilence inspects the Opus payload. Packets without audio data (DTX) or
+// with a very small payload carry no speech.
+func (g *SilenceGate) isSilence(packet *rtp.Packet) bool {
+	return len(packet.Payload) <= g.config.MaxPayload
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"sync"
+	"time"
 )
 
+// How long Connect waits for the server to send the UDP address, including
+// the capability negotiation.
+const udpAddressTimeout = helloTimeout + 5*time.Second
+
 type StreamClient struct {
 	tcpClient  *TCPclient
 	udpClient  *UDPclient
@@ -16,13 +23,24 @@ type StreamClient struct {
 
 	status status
 
+	capabilitiesLock sync.Mutex
+	capabilities     Capabilities
+
 	connectedEvent *Event
-	messageEvent *Event
+	// Transcripts have to be handled in the order they were received
+	messageEvent  *Event
+	languageEvent *Event
+	onMessage     func(message string)
 }
 
-func NewStreamClient(host string, port int, useEncryption bool, secretToken string) *StreamClient {
+// NewStreamClient creates a client for the transcription server. If tlsConfig
+// is not nil, the TCP channel runs over TLS instead of the RSA/AES exchange.
+// If keyVerifier is not nil, the server's public key is checked against it.
+func NewStreamClient(host string, port int, useEncryption bool, secretToken string, tlsConfig *tls.Config, keyVerifier *KeyVerifier) *StreamClient {
 	tcpClient := NewTCPclient(fmt.Sprintf("%s:%d", host, port), useEncryption)
 	tcpClient.Secret_token = secretToken
+	tcpClient.TLSConfig = tlsConfig
+	tcpClient.KeyVerifier = keyVerifier
 
 	return &StreamClient{
 		tcpClient:  tcpClient,
@@ -32,7 +50,8 @@ func NewStreamClient(host string, port int, useEncryption bool, secretToken stri
 		status: DISCONNECTED,
 
 		connectedEvent: NewEvent(),
-		messageEvent:   NewEvent(),
+		messageEvent:   NewOrderedEvent(),
+		languageEvent:  NewEvent(),
 	}
 }
 
@@ -45,8 +64,29 @@ type MessageType int
 const (
 	MESSAGE MessageType = iota
 	INIT_UDPADDRESS
+	LOSS_REPORT
+	HELLO
+	LANGUAGE
 )
 
+// LossReport is sent periodically by the transcription server if it reads the
+// UDP sequencing header.
+type LossReport struct {
+	Received   uint64    `json:"received"`
+	Lost       uint64    `json:"lost"`
+	Reordered  uint64    `json:"reordered"`
+	Duplicates uint64    `json:"duplicates"`
+	HighestSeq uint32    `json:"highest_seq"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// LanguageDetection is sent by the transcription server before the segments
+// of a newly detected spoken language.
+type LanguageDetection struct {
+	Language    string  `json:"language"`
+	Probability float64 `json:"probability"`
+}
+
 func (sc *StreamClient) getMessageType(message string) (MessageType, error) {
 	var msgtype messageTypeStruct
 	err := json.Unmarshal([]byte(message), &msgtype)
@@ -59,6 +99,12 @@ func (sc *StreamClient) getMessageType(message string) (MessageType, error) {
 		return MESSAGE, nil
 	case "init_udpaddr":
 		return INIT_UDPADDRESS, nil
+	case "loss_report":
+		return LOSS_REPORT, nil
+	case "hello":
+		return HELLO, nil
+	case "language":
+		return LANGUAGE, nil
 	}
 
 	return 0, errors.New("unknown message type")
@@ -66,21 +112,43 @@ func (sc *StreamClient) getMessageType(message string) (MessageType, error) {
 
 func (sc *StreamClient) Connect() error {
 	sc.status = CONNECTING
+	sc.setCapabilities(Capabilities{Protocol: 1, Features: []string{}})
 
-	sc.tcpClient.OnMessage(func(message string) {
-		if sc.status == CONNECTED {
-			sc.messageEvent.Emit(message)
+	if sc.onMessage != nil {
+		sc.tcpClient.RemoveOnMessageInOrder(sc.onMessage)
+	}
+	sc.onMessage = func(message string) {
+		if sc.status != CONNECTED {
+			return
 		}
-	})
+		if msgtype, err := sc.getMessageType(message); err == nil {
+			switch msgtype {
+			case LOSS_REPORT:
+				sc.handleLossReport(message)
+				return
+			case LANGUAGE:
+				sc.handleLanguage(message)
+				return
+			}
+		}
+		sc.messageEvent.Emit(message)
+	}
+	sc.tcpClient.OnMessageInOrder(sc.onMessage)
 	disconnectedhandler := func(message string) {
 		sc.status = DISCONNECTED
 	}
 	sc.tcpClient.OnDisconnected(disconnectedhandler)
 	sc.tcpClient.OnTimeout(disconnectedhandler)
 
-	// Mutex to wait until the server has sent the UDP address
-	onmsgmutex := sync.Mutex{}
-	onmsgmutex.Lock()
+	// Signalled once the server has sent the UDP address or setting up the
+	// audio channel failed
+	initialized := make(chan error, 1)
+	signal := func(err error) {
+		select {
+		case initialized <- err:
+		default:
+		}
+	}
 
 	var init_udpserver func(message string)
 	init_udpserver = func(message string) {
@@ -99,7 +167,9 @@ func (sc *StreamClient) Connect() error {
 						Host       string `json:"host"`
 						Port       int    `json:"port"`
 						Encryption bool   `json:"encryption"`
+						Sequence   bool   `json:"sequence"`
 					} `json:"udp"`
+					Protocol int `json:"protocol"`
 				} `json:"msg"`
 			}
 			var udpAddr udpAddrStruct
@@ -107,14 +177,45 @@ func (sc *StreamClient) Connect() error {
 			if err != nil {
 				fmt.Println("Failed to unmarshal UDP address:", err)
 				sc.Close()
+				signal(err)
+				return
+			}
+
+			// The hello reply must not end up in this handler
+			sc.tcpClient.RemoveOnMessage(init_udpserver)
+
+			capabilities := Capabilities{
+				Protocol: 1,
+				Cipher:   sc.tcpClient.GetCipherMode(),
+				Features: []string{},
+			}
+			if udpAddr.Msg.Protocol >= protocolVersion {
+				capabilities, err = sc.negotiate()
+				if err != nil {
+					log.Printf("[ERROR] Failed to negotiate capabilities: %v", err)
+					sc.Close()
+					signal(err)
+					return
+				}
+			}
+			sc.setCapabilities(capabilities)
+			log.Printf("[INFO] Negotiated capabilities: %+v", capabilities)
+			sequenced := udpAddr.Msg.UDP.Sequence || capabilities.Has(FeatureUDPSequence)
+
+			udpCipher, err := sc.tcpClient.NewUDPCipher()
+			if err != nil {
+				fmt.Println("Failed to create UDP cipher:", err)
+				sc.Close()
+				signal(err)
 				return
 			}
 
-			udpClient := NewUDPclient(udpAddr.Msg.UDP.Host+":"+strconv.Itoa(udpAddr.Msg.UDP.Port), udpAddr.Msg.UDP.Encryption, sc.tcpClient.GetAESkey(), sc.tcpClient.GetAESiv())
+			udpClient := NewUDPclient(udpAddr.Msg.UDP.Host+":"+strconv.Itoa(udpAddr.Msg.UDP.Port), udpAddr.Msg.UDP.Encryption, sequenced, sc.tcpClient.GetAESkey(), sc.tcpClient.GetAESiv(), udpCipher)
 			err = udpClient.Connect()
 			if err != nil {
 				fmt.Println("Failed to connect to UDP server:", err)
 				sc.Close()
+				signal(err)
 				return
 			}
 
@@ -123,7 +224,7 @@ func (sc *StreamClient) Connect() error {
 
 		sc.tcpClient.RemoveOnMessage(init_udpserver)
 
-		onmsgmutex.Unlock()
+		signal(nil)
 	}
 
 	sc.tcpClient.OnMessage(init_udpserver)
@@ -133,8 +234,16 @@ func (sc *StreamClient) Connect() error {
 		return err
 	}
 
-	onmsgmutex.Lock()
-	defer onmsgmutex.Unlock()
+	select {
+	case err := <-initialized:
+		if err != nil {
+			return err
+		}
+	case <-time.After(udpAddressTimeout):
+		sc.tcpClient.RemoveOnMessage(init_udpserver)
+		sc.Close()
+		return fmt.Errorf("no UDP address from server within %s", udpAddressTimeout)
+	}
 
 	sc.connectedEvent.Emit("connected")
 	sc.status = CONNECTED
@@ -160,6 +269,41 @@ func (sc *StreamClient) SendUDPMessage(data []byte) error {
 	return sc.udpClient.SendMessage(data)
 }
 
+func (sc *StreamClient) handleLossReport(message string) {
+	var report struct {
+		Msg LossReport `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(message), &report); err != nil {
+		fmt.Println("Failed to unmarshal loss report:", err)
+		return
+	}
+	report.Msg.ReceivedAt = time.Now()
+
+	if sc.udpClient != nil {
+		sc.udpClient.SetLossReport(report.Msg)
+	}
+}
+
+func (sc *StreamClient) handleLanguage(message string) {
+	var detection struct {
+		Msg LanguageDetection `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(message), &detection); err != nil {
+		log.Printf("[WARN] Failed to unmarshal language detection: %v", err)
+		return
+	}
+	log.Printf("[INFO] Detected language: %s (%.2f)", detection.Msg.Language, detection.Msg.Probability)
+	sc.languageEvent.Emit(detection.Msg.Language)
+}
+
+// UDPStats returns the statistics of the audio channel.
+func (sc *StreamClient) UDPStats() UDPStats {
+	if sc.udpClient == nil {
+		return UDPStats{}
+	}
+	return sc.udpClient.Stats()
+}
+
 func (sc *StreamClient) Write(p []byte) (int, error) {
 	err := sc.SendUDPMessage(p)
 	return len(p), err
@@ -191,6 +335,16 @@ func (sc *StreamClient) RemoveOnTCPMessage(handler func(message string)) {
 	sc.messageEvent.Remove(handler)
 }
 
+// OnLanguage is called with the language code whenever the server detects
+// a change of the spoken language.
+func (sc *StreamClient) OnLanguage(handler func(language string)) {
+	sc.languageEvent.Add(handler)
+}
+
+func (sc *StreamClient) RemoveOnLanguage(handler func(language string)) {
+	sc.languageEvent.Remove(handler)
+}
+
 // on disconnected
 func (sc *StreamClient) OnDisconnected(handler func(message string)) {
 	sc.tcpClient.OnDisconnected(handler)
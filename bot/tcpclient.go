@@ -6,9 +6,13 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/pem"
 	"fmt"
+	"io"
+	"log"
 	"net"
 	"sync"
 	"time"
@@ -30,16 +34,23 @@ type TCPclient struct {
 	aesIV             []byte
 	running           bool
 	encryptionEnabled bool
+	cipherMode        CipherMode
+	sessionCipher     *sessionCipher
 	serverPublicKey   *rsa.PublicKey
 	BufferSize        int
 	PingTimeIntervall time.Duration
 	StopChan          chan bool
+	// If set, the connection runs over TLS instead of the RSA/AES exchange
+	TLSConfig *tls.Config
+	// If set, the server's public key is checked before the token is sent
+	KeyVerifier *KeyVerifier
 
 	Secret_token string
 
 	status status
 
 	messageEvent      *Event
+	orderedEvent      *Event
 	connectedEvent    *Event
 	disconnectedEvent *Event
 	timeoutEvent      *Event
@@ -57,6 +68,8 @@ func NewTCPclient(addr string, encryption bool) *TCPclient {
 		aesIV:             nil,
 		running:           false,
 		encryptionEnabled: encryption,
+		cipherMode:        CipherLegacyCFB,
+		sessionCipher:     nil,
 		serverPublicKey:   nil,
 		BufferSize:        1024,
 		PingTimeIntervall: 4 * time.Second,
@@ -67,6 +80,7 @@ func NewTCPclient(addr string, encryption bool) *TCPclient {
 		status: DISCONNECTED,
 
 		messageEvent:      NewEvent(),
+		orderedEvent:      NewOrderedEvent(),
 		connectedEvent:    NewEvent(),
 		disconnectedEvent: NewEvent(),
 		timeoutEvent:      NewEvent(),
@@ -84,11 +98,45 @@ func (c *TCPclient) GetAESiv() []byte {
 	return c.aesIV
 }
 
+// GetCipherMode returns the encryption mode negotiated with the server.
+func (c *TCPclient) GetCipherMode() CipherMode {
+	return c.cipherMode
+}
+
+// NewUDPCipher returns a cipher for the UDP channel using the negotiated
+// AEAD mode, or nil if the legacy mode is in use.
+func (c *TCPclient) NewUDPCipher() (*sessionCipher, error) {
+	if c.cipherMode == CipherTLS {
+		tlsConn, ok := c.connection.(*tls.Conn)
+		if !ok {
+			return nil, fmt.Errorf("connection is not a TLS connection")
+		}
+		state := tlsConn.ConnectionState()
+		key, err := state.ExportKeyingMaterial(tlsExporterLabel, nil, 32)
+		if err != nil {
+			return nil, err
+		}
+		return newSessionCipher(CipherAESGCM, key, nonceUDPClientToServer, 0)
+	}
+	if !c.encryptionEnabled || c.cipherMode == CipherLegacyCFB {
+		return nil, nil
+	}
+	return newSessionCipher(c.cipherMode, c.aesKey, nonceUDPClientToServer, 0)
+}
+
 func (c *TCPclient) Send(message string) error {
 	c.msgSendLock.Lock()
 	defer c.msgSendLock.Unlock()
 
-	if c.encryptionEnabled {
+	if c.sessionCipher != nil {
+		// Length prefixed frame: nonce + ciphertext + tag
+		sealed := c.sessionCipher.Seal([]byte(message))
+		frame := make([]byte, 4+len(sealed))
+		binary.BigEndian.PutUint32(frame[0:4], uint32(len(sealed)))
+		copy(frame[4:], sealed)
+		_, err := c.connection.Write(frame)
+		return err
+	} else if c.encryptionEnabled && c.TLSConfig == nil {
 		blockCipher, err := aes.NewCipher(c.aesKey)
 		if err != nil {
 			fmt.Println("Failed to create AES cipher:", err)
@@ -99,10 +147,9 @@ func (c *TCPclient) Send(message string) error {
 		stream := cipher.NewCFBEncrypter(blockCipher, c.aesIV)
 		encryptedToken := make([]byte, len(message))
 		stream.XORKeyStream(encryptedToken, []byte(message))
-		fmt.Println("plaintext bytes: ", []byte(message))
-		fmt.Println("encryptedToken: ", encryptedToken)
 
-		c.connection.Write(encryptedToken)
+		_, err = c.connection.Write(encryptedToken)
+		return err
 	} else {
 		_, err := c.connection.Write([]byte(message))
 		if err != nil {
@@ -120,7 +167,10 @@ func (c *TCPclient) exchangeKeys() error {
 		return err
 	}
 	serverPublicKeyPEM := keyBuffer[:n]
-	block, _ := pem.Decode(serverPublicKeyPEM)
+	block, rest := pem.Decode(serverPublicKeyPEM)
+	if block == nil {
+		return fmt.Errorf("server did not send a PEM encoded public key")
+	}
 	pubInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
 		return err
@@ -130,18 +180,29 @@ func (c *TCPclient) exchangeKeys() error {
 	if !ok {
 		return fmt.Errorf("could not cast public key to *rsa.PublicKey")
 	}
-	fmt.Println("Received server public key:" + string(serverPublicKeyPEM) + "\n")
+	if c.KeyVerifier != nil {
+		if err := c.KeyVerifier.Verify(c.address, block.Bytes); err != nil {
+			return err
+		}
+	}
+	log.Printf("[INFO] Received public key of transcription server %s", c.address)
+
+	// Newer servers offer AEAD modes after the public key
+	offered, label := parseCipherOffer(rest)
+	c.cipherMode = selectCipher(offered)
+	log.Printf("[INFO] Selected cipher mode %s for %s", c.cipherMode, c.address)
+	if c.cipherMode != CipherLegacyCFB {
+		return c.exchangeSessionKey(label)
+	}
 
 	// Erzeugung des AES-Schlüssels und IVs
 	c.aesKey = make([]byte, 32) // 256-bit AES key
 	c.aesIV = make([]byte, 16)  // AES IV
 	rand.Read(c.aesKey)
 	rand.Read(c.aesIV)
-	fmt.Println("Generated AES Key:", c.aesKey)
-	fmt.Println("Generated AES IV:", c.aesIV)
 
 	// Verschlüsseln des AES-Schlüssels und IVs mit dem RSA-Schlüssel des Servers
-	encryptedKeyIV, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, c.serverPublicKey, append(c.aesIV, c.aesKey...), nil)
+	encryptedKeyIV, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, c.serverPublicKey, append(c.aesIV, c.aesKey...), label)
 	if err != nil {
 		return err
 	}
@@ -154,19 +215,112 @@ func (c *TCPclient) exchangeKeys() error {
 	return nil
 }
 
+// exchangeSessionKey sends version + cipher id + key to the server and sets up
+// the AEAD for the TCP channel. label binds the cipher offer to the message.
+func (c *TCPclient) exchangeSessionKey(label []byte) error {
+	c.aesKey = make([]byte, 32)
+	c.aesIV = nil
+	if _, err := rand.Read(c.aesKey); err != nil {
+		return err
+	}
+
+	keyMessage := append([]byte{keyExchangeVersion, c.cipherMode.id()}, c.aesKey...)
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, c.serverPublicKey, keyMessage, label)
+	if err != nil {
+		return err
+	}
+	if _, err := c.connection.Write(encryptedKey); err != nil {
+		return err
+	}
+
+	c.sessionCipher, err = newSessionCipher(c.cipherMode, c.aesKey, nonceTCPClientToServer, nonceTCPServerToClient)
+	return err
+}
+
+// verifyTLSKey checks the public key of the server's TLS certificate.
+func (c *TCPclient) verifyTLSKey() error {
+	if c.KeyVerifier == nil {
+		return nil
+	}
+	tlsConn, ok := c.connection.(*tls.Conn)
+	if !ok {
+		return fmt.Errorf("connection is not a TLS connection")
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("server did not present a certificate")
+	}
+	return c.KeyVerifier.Verify(c.address, certs[0].RawSubjectPublicKeyInfo)
+}
+
+// readMessage reads the next message from the connection. AEAD frames are
+// length prefixed, legacy messages are whatever one read returns.
+func (c *TCPclient) readMessage() ([]byte, error) {
+	if c.sessionCipher == nil {
+		messageBuffer := make([]byte, c.BufferSize)
+		n, err := c.connection.Read(messageBuffer)
+		if err != nil {
+			return nil, err
+		}
+		return messageBuffer[:n], nil
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c.connection, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("frame too large: %d bytes", size)
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(c.connection, frame); err != nil {
+		return nil, err
+	}
+	return c.sessionCipher.Open(frame)
+}
+
+// decryptLegacy decrypts a message of the legacy AES-CFB mode. Messages of
+// the other modes are returned as they are, readMessage already opened them.
+func (c *TCPclient) decryptLegacy(message []byte) ([]byte, error) {
+	if !c.encryptionEnabled || c.sessionCipher != nil || c.TLSConfig != nil {
+		return message, nil
+	}
+	blockCipher, err := aes.NewCipher(c.aesKey)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCFBDecrypter(blockCipher, c.aesIV)
+	decryptedMessage := make([]byte, len(message))
+	stream.XORKeyStream(decryptedMessage, message)
+	return decryptedMessage, nil
+}
+
 func (c *TCPclient) Connect() error {
 	c.status = CONNECTING
 	c.StopChan = make(chan bool)
 
 	var err error
-	c.connection, err = net.Dial("tcp", c.address)
+	if c.TLSConfig != nil {
+		c.connection, err = tls.Dial("tcp", c.address, c.TLSConfig)
+	} else {
+		c.connection, err = net.Dial("tcp", c.address)
+	}
 	if err != nil {
 		return err
 	}
 
 	c.running = true
-
-	go c.receive()
+	c.cipherMode = CipherLegacyCFB
+	c.sessionCipher = nil
+	if c.TLSConfig != nil {
+		c.cipherMode = CipherTLS
+		if err := c.verifyTLSKey(); err != nil {
+			c.running = false
+			c.connection.Close()
+			return err
+		}
+	}
 
 	// Wait for OK message from server
 	// Mutex to wait for event to be handled
@@ -183,13 +337,20 @@ func (c *TCPclient) Connect() error {
 	}
 	c.messageEvent.Add(onmsg)
 
-	if c.encryptionEnabled {
+	// TLS already encrypts the channel
+	if c.encryptionEnabled && c.TLSConfig == nil {
 		err := c.exchangeKeys()
 		if err != nil {
+			c.running = false
+			c.connection.Close()
 			return err
 		}
 	}
 
+	// Start reading only after the key exchange, which reads the public key
+	// itself and decides how messages are framed.
+	go c.receive()
+
 	onmsgmutex.Lock()
 	defer onmsgmutex.Unlock()
 	// Send secret token to server
@@ -229,24 +390,16 @@ func (c *TCPclient) receive() {
 		case <-c.StopChan:
 			return
 		default:
-			messageBuffer := make([]byte, c.BufferSize)
-			n, err := c.connection.Read(messageBuffer)
+			message, err := c.readMessage()
 			if err != nil {
 				c.timeoutEvent.Emit("Connection timed out.")
 				c.Close()
 				return
 			}
-			message := messageBuffer[:n]
-			if c.encryptionEnabled {
-				blockCipher, err := aes.NewCipher(c.aesKey)
-				if err != nil {
-					fmt.Println("Failed to create AES cipher:", err)
-					return
-				}
-				stream := cipher.NewCFBDecrypter(blockCipher, c.aesIV)
-				decryptedMessage := make([]byte, len(message))
-				stream.XORKeyStream(decryptedMessage, message)
-				message = decryptedMessage
+			message, err = c.decryptLegacy(message)
+			if err != nil {
+				fmt.Println("Failed to create AES cipher:", err)
+				return
 			}
 
 			if string(message) == "PONG" {
@@ -254,9 +407,8 @@ func (c *TCPclient) receive() {
 				continue
 			}
 
-			fmt.Println("MSG", string(message))
-
 			c.messageEvent.Emit(string(message))
+			c.orderedEvent.Emit(string(message))
 		}
 	}
 }
@@ -299,6 +451,16 @@ func (c *TCPclient) RemoveOnMessage(handler func(message string)) {
 	c.messageEvent.Remove(handler)
 }
 
+// OnMessageInOrder adds a handler which gets all messages, including those
+// of the handshake, one after another in the order they were received.
+func (c *TCPclient) OnMessageInOrder(handler func(message string)) {
+	c.orderedEvent.Add(handler)
+}
+
+func (c *TCPclient) RemoveOnMessageInOrder(handler func(message string)) {
+	c.orderedEvent.Remove(handler)
+}
+
 func (c *TCPclient) OnDisconnected(handler func(message string)) {
 	c.disconnectedEvent.Add(handler)
 }
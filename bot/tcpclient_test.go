@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The probe sends the secret token with Send, so Send must not print what
+// it sends.
+func TestSendDoesNotPrintMessage(t *testing.T) {
+	const secret = "probe-secret-token"
+
+	for _, tt := range []struct {
+		name       string
+		encryption bool
+	}{
+		{"plain", false},
+		{"legacy", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			c := NewTCPclient("test", tt.encryption)
+			c.connection = client
+			c.aesKey = bytes.Repeat([]byte{1}, 16)
+			c.aesIV = bytes.Repeat([]byte{2}, 16)
+
+			stdout := os.Stdout
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Stdout = w
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			go io.Copy(io.Discard, server)
+			err = c.Send(secret)
+			client.Close()
+			os.Stdout = stdout
+			w.Close()
+			printed, _ := io.ReadAll(r)
+
+			if err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if output := string(printed) + logs.String(); strings.Contains(output, secret) {
+				t.Fatalf("secret printed: %q", output)
+			}
+		})
+	}
+}
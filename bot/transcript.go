@@ -0,0 +1,59 @@
+package main
+
+import "encoding/json"
+
+// Transcript is one segment sent by the transcription server as
+//
+//	{"type": "msg", "msg": {"segment_id": "...", "confirmed": "...", ...}}
+//
+// The server keeps sending a segment until it is final. Confirmed words no
+// longer change, provisional words are a draft which may still be replaced.
+type Transcript struct {
+	SegmentID   string `json:"segment_id"`
+	Confirmed   string `json:"confirmed"`
+	Provisional string `json:"provisional"`
+	// Seconds since the start of the stream
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Spoken language of the segment, empty if the server does not detect it
+	Language   string  `json:"language,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Final      bool    `json:"final"`
+}
+
+// Text returns the confirmed words followed by the draft.
+func (t Transcript) Text() string {
+	if t.Provisional == "" {
+		return t.Confirmed
+	}
+	if t.Confirmed == "" {
+		return t.Provisional
+	}
+	return t.Confirmed + " " + t.Provisional
+}
+
+// ParseTranscript reads a transcript message. Older servers send the caption
+// text as it is, or as a string in msg; both are treated as final text. Other
+// JSON messages are no transcript, ok is false for them.
+func ParseTranscript(message string) (transcript Transcript, ok bool) {
+	var envelope struct {
+		Type string          `json:"type"`
+		Msg  json.RawMessage `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(message), &envelope); err != nil {
+		return Transcript{Confirmed: message, Final: true}, true
+	}
+	if envelope.Type != "msg" {
+		return Transcript{}, false
+	}
+
+	var text string
+	if err := json.Unmarshal(envelope.Msg, &text); err == nil {
+		return Transcript{Confirmed: text, Final: true}, true
+	}
+
+	if err := json.Unmarshal(envelope.Msg, &transcript); err != nil {
+		return Transcript{}, false
+	}
+	return transcript, true
+}
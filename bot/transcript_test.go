@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestParseTranscript(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    Transcript
+		wantOK  bool
+	}{
+		{"plain text", "Hello world", Transcript{Confirmed: "Hello world", Final: true}, true},
+		{"text in msg", `{"type": "msg", "msg": "Hello"}`, Transcript{Confirmed: "Hello", Final: true}, true},
+		{
+			"segment",
+			`{"type": "msg", "msg": {"segment_id": "1", "confirmed": "Hello", "provisional": "wor", "language": "pt"}}`,
+			Transcript{SegmentID: "1", Confirmed: "Hello", Provisional: "wor", Language: "pt"},
+			true,
+		},
+		{"other type", `{"type": "stats", "msg": {"cpu": 1}}`, Transcript{}, false},
+		{"no type", `{"text": "Hello"}`, Transcript{}, false},
+		{"broken segment", `{"type": "msg", "msg": 42}`, Transcript{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseTranscript(tt.message)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %+v %v, want %+v %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
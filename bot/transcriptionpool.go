@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TranscriptionServer is one entry of the transcription server pool.
+type TranscriptionServer struct {
+	Host            string    `json:"host"`
+	Port            int       `json:"port"`
+	HealthCheckPort int       `json:"health_check_port"`
+	Healthy         bool      `json:"healthy"`
+	Bots            int       `json:"bots"`
+	LastCheck       time.Time `json:"last_check"`
+}
+
+// Address returns host:port of the TCP channel.
+func (s *TranscriptionServer) Address() string {
+	return s.Host + ":" + strconv.Itoa(s.Port)
+}
+
+func (s *TranscriptionServer) healthURL() string {
+	return "http://" + s.Host + ":" + strconv.Itoa(s.HealthCheckPort) + "/health"
+}
+
+// TranscriptionPool keeps track of all transcription servers, their health
+// and how many bots stream to each of them.
+type TranscriptionPool struct {
+	lock    sync.Mutex
+	servers []*TranscriptionServer
+	client  *http.Client
+}
+
+func NewTranscriptionPool(servers []*TranscriptionServer) *TranscriptionPool {
+	return &TranscriptionPool{
+		servers: servers,
+		client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// Acquire assigns a bot to the healthy server with the fewest bots. Servers in
+// exclude are skipped.
+func (p *TranscriptionPool) Acquire(exclude ...*TranscriptionServer) (*TranscriptionServer, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var best *TranscriptionServer
+	for _, server := range p.servers {
+		if !server.Healthy || containsServer(exclude, server) {
+			continue
+		}
+		if best == nil || server.Bots < best.Bots {
+			best = server
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no healthy transcription server available")
+	}
+	best.Bots++
+	return best, nil
+}
+
+// Release removes a bot assignment from the server.
+func (p *TranscriptionPool) Release(server *TranscriptionServer) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if server.Bots > 0 {
+		server.Bots--
+	}
+}
+
+// MarkDown takes the server out of rotation until the next successful health
+// check.
+func (p *TranscriptionPool) MarkDown(server *TranscriptionServer) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	server.Healthy = false
+}
+
+// Servers returns a snapshot of all servers.
+func (p *TranscriptionPool) Servers() []TranscriptionServer {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	servers := make([]TranscriptionServer, 0, len(p.servers))
+	for _, server := range p.servers {
+		servers = append(servers, *server)
+	}
+	return servers
+}
+
+// CheckHealth probes every server and returns the number of healthy ones.
+func (p *TranscriptionPool) CheckHealth() int {
+	p.lock.Lock()
+	servers := make([]*TranscriptionServer, len(p.servers))
+	copy(servers, p.servers)
+	p.lock.Unlock()
+
+	healthy := 0
+	for _, server := range servers {
+		ok := p.probe(server)
+
+		p.lock.Lock()
+		if ok != server.Healthy {
+			log.Printf("[INFO] Transcription server %s healthy: %t", server.Address(), ok)
+		}
+		server.Healthy = ok
+		server.LastCheck = time.Now()
+		p.lock.Unlock()
+
+		if ok {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+func (p *TranscriptionPool) probe(server *TranscriptionServer) bool {
+	resp, err := p.client.Get(server.healthURL())
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+func containsServer(servers []*TranscriptionServer, server *TranscriptionServer) bool {
+	for _, s := range servers {
+		if s == server {
+			return true
+		}
+	}
+	return false
+}
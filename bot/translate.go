@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strings"
 )
 
 // ---------------------- HELPER FUNCTIONS FOR TRANSLATION ----------------------
@@ -64,6 +66,26 @@ func ConvertBBBToLibretranslate(bbbCode string) string {
 	return ""
 }
 
+// ConvertISOToBBB converts a language code of the transcription server, an
+// ISO 639-1 code like "cs" or "pt", to the BBB language code. BBB codes are
+// accepted as well, ignoring case.
+func ConvertISOToBBB(code string) (string, bool) {
+	if lang, ok := bbbLanguage(code); ok {
+		return lang, true
+	}
+	candidates := make([]string, 0)
+	for bbbCode, iso := range bbbToLibretranslate {
+		if strings.EqualFold(iso, code) {
+			candidates = append(candidates, bbbCode)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sort.Strings(candidates)
+	return candidates[0], true
+}
+
 // TranslationRequest struct to hold the request payload for translation
 type TranslationRequest struct {
 	Q      string `json:"q"`
@@ -83,10 +105,15 @@ func translate(apiURL, text, sourceLang, targetLang string) (string, error) {
 		return "", fmt.Errorf("unsupported language: %s", targetLang)
 	}
 
+	libreSourceLang := ConvertBBBToLibretranslate(sourceLang)
+	if libreSourceLang == "" {
+		return "", fmt.Errorf("unsupported language: %s", sourceLang)
+	}
+
 	// Create the request payload
 	requestPayload := TranslationRequest{
 		Q:      text,
-		Source: sourceLang,
+		Source: libreSourceLang,
 		Target: linreTargetLang,
 	}
 
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestConvertISOToBBB(t *testing.T) {
+	tests := []struct {
+		code   string
+		want   string
+		wantOK bool
+	}{
+		{"de", "de", true},
+		{"cs", "cs-CZ", true},
+		{"zh", "zh-CN", true},
+		{"pt", "pt", true},
+		{"es", "es", true},
+		{"pt-BR", "pt-BR", true},
+		{"pt-br", "pt-BR", true},
+		{"BG-bg", "bg-BG", true},
+		{"EN", "en", true},
+		{"xx", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, ok := ConvertISOToBBB(tt.code)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %q %v, want %q %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -3,24 +3,54 @@ package main
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"encoding/binary"
 	"fmt"
 	"net"
+	"sync"
+	"time"
 )
 
+// Size of the sequencing header: 4 bytes sequence number + 8 bytes timestamp
+const udpHeaderSize = 12
+
+// UDPStats holds the sender side counters of the audio channel and the last
+// loss report received from the transcription server.
+type UDPStats struct {
+	Sequenced bool   `json:"sequenced"`
+	Sent      uint64 `json:"sent"`
+	Bytes     uint64 `json:"bytes"`
+	LastSeq   uint32 `json:"last_seq"`
+
+	Report *LossReport `json:"report,omitempty"`
+}
+
 type UDPclient struct {
 	serverAddr string
 	conn       *net.UDPConn
 	aesKey     []byte
 	aesIV      []byte
-	Encrypted  bool
+	// AEAD for the negotiated mode, nil for legacy AES-CFB
+	aead      *sessionCipher
+	Encrypted bool
+	// Prefix every packet with a sequence number and a send timestamp
+	Sequenced bool
+
+	lock  sync.Mutex
+	seq   uint32
+	stats UDPStats
 }
 
-func NewUDPclient(serverAddr string, encrypted bool, aeskey, aesiv []byte) *UDPclient {
+func NewUDPclient(serverAddr string, encrypted bool, sequenced bool, aeskey, aesiv []byte, aead *sessionCipher) *UDPclient {
 	return &UDPclient{
 		serverAddr: serverAddr,
 		aesKey:     aeskey,
 		aesIV:      aesiv,
+		aead:       aead,
 		Encrypted:  encrypted,
+		Sequenced:  sequenced,
+		stats: UDPStats{
+			Sequenced: sequenced,
+		},
 	}
 }
 
@@ -44,6 +74,13 @@ func (c *UDPclient) Close() {
 }
 
 func (c *UDPclient) SendMessage(message []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.Sequenced {
+		message = c.addHeader(message)
+	}
+
 	if c.Encrypted {
 		var err error
 		message, err = c.encryptMessage(message)
@@ -57,10 +94,59 @@ func (c *UDPclient) SendMessage(message []byte) error {
 		return fmt.Errorf("Error sending message: %v", err)
 	}
 
+	c.stats.Sent++
+	c.stats.Bytes += uint64(len(message))
+
 	return nil
 }
 
+// Write sends p as one datagram, so an Ogg writer can write to the client.
+func (c *UDPclient) Write(p []byte) (int, error) {
+	err := c.SendMessage(p)
+	return len(p), err
+}
+
+// addHeader prepends the sequence number and the send time in microseconds
+// since the unix epoch, both big endian.
+func (c *UDPclient) addHeader(message []byte) []byte {
+	packet := make([]byte, udpHeaderSize+len(message))
+	binary.BigEndian.PutUint32(packet[0:4], c.seq)
+	binary.BigEndian.PutUint64(packet[4:12], uint64(time.Now().UnixMicro()))
+	copy(packet[udpHeaderSize:], message)
+
+	c.stats.LastSeq = c.seq
+	c.seq++
+	return packet
+}
+
+// SetLossReport stores the latest loss report of the server.
+func (c *UDPclient) SetLossReport(report LossReport) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stats.Report = &report
+}
+
+// Stats returns a copy of the current counters.
+func (c *UDPclient) Stats() UDPStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stats := c.stats
+	if stats.Report != nil {
+		report := *stats.Report
+		stats.Report = &report
+	}
+	return stats
+}
+
 func (c *UDPclient) encryptMessage(message []byte) ([]byte, error) {
+	if c.aead != nil {
+		// nonce + ciphertext + tag. The nonce counter increases with every
+		// packet, so the server can drop replayed packets.
+		return c.aead.Seal(message), nil
+	}
+
 	blockCipher, err := aes.NewCipher(c.aesKey)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watchdog periodically checks all dependencies and keeps their latest
+// status. Handlers registered with OnRecovered and OnDown are called with the
+// dependency name whenever its health changes.
+type Watchdog struct {
+	lock     sync.Mutex
+	deps     []dependency
+	statuses map[string]DependencyStatus
+	interval time.Duration
+
+	recoveredEvent *Event
+	downEvent      *Event
+}
+
+func NewWatchdog(deps []dependency, interval time.Duration) *Watchdog {
+	return &Watchdog{
+		deps:     deps,
+		statuses: make(map[string]DependencyStatus),
+		interval: interval,
+
+		recoveredEvent: NewEvent(),
+		downEvent:      NewEvent(),
+	}
+}
+
+// Run checks all dependencies right away and then every interval until stop
+// is closed.
+func (w *Watchdog) Run(stop <-chan struct{}) {
+	w.Check(context.Background())
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.Check(context.Background())
+		}
+	}
+}
+
+// Check runs all dependency checks once and updates the stored statuses.
+func (w *Watchdog) Check(ctx context.Context) {
+	statuses := checkDependencies(ctx, w.deps)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, status := range statuses {
+		previous, known := w.statuses[status.Name]
+		w.statuses[status.Name] = status
+
+		switch {
+		case status.Healthy && known && !previous.Healthy:
+			log.Printf("[INFO] Dependency %s recovered", status.Name)
+			w.recoveredEvent.Emit(status.Name)
+		case !status.Healthy && (!known || previous.Healthy):
+			log.Printf("[ERROR] Dependency %s is down: %s", status.Name, status.Error)
+			w.downEvent.Emit(status.Name)
+		}
+	}
+}
+
+// Statuses returns the latest status of every dependency in check order.
+func (w *Watchdog) Statuses() []DependencyStatus {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	statuses := make([]DependencyStatus, 0, len(w.deps))
+	for _, dep := range w.deps {
+		if status, ok := w.statuses[dep.name]; ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// Status returns the latest status of the named dependency.
+func (w *Watchdog) Status(name string) (DependencyStatus, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	status, ok := w.statuses[name]
+	return status, ok
+}
+
+// Ready returns an error naming all required dependencies which are down or
+// have not been checked yet.
+func (w *Watchdog) Ready() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	down := make([]string, 0)
+	for _, dep := range w.deps {
+		if !dep.required {
+			continue
+		}
+		status, ok := w.statuses[dep.name]
+		if !ok {
+			down = append(down, dep.name+" (not checked yet)")
+		} else if !status.Healthy {
+			down = append(down, dep.name)
+		}
+	}
+	if len(down) > 0 {
+		return fmt.Errorf("required dependencies unavailable: %s", strings.Join(down, ", "))
+	}
+	return nil
+}
+
+func (w *Watchdog) OnRecovered(handler func(name string)) {
+	w.recoveredEvent.Add(handler)
+}
+
+func (w *Watchdog) RemoveOnRecovered(handler func(name string)) {
+	w.recoveredEvent.Remove(handler)
+}
+
+func (w *Watchdog) OnDown(handler func(name string)) {
+	w.downEvent.Add(handler)
+}
+
+func (w *Watchdog) RemoveOnDown(handler func(name string)) {
+	w.downEvent.Remove(handler)
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bot lifecycle events sent to webhook subscribers
+const (
+	EventBotCreated         = "bot.created"
+	EventBotJoined          = "bot.joined"
+	EventBotJoinFailed      = "bot.join_failed"
+	EventBotTaskChanged     = "bot.task_changed"
+	EventBotLanguageAdded   = "bot.language_added"
+	EventBotLanguageRemoved = "bot.language_removed"
+	EventBotStreamLost      = "bot.stream_disconnected"
+	EventBotCaptionLost     = "bot.caption_disconnected"
+	EventBotLanguageChanged = "bot.language_detected"
+	EventBotRemoved         = "bot.removed"
+
+	EventDependencyDown      = "dependency.down"
+	EventDependencyRecovered = "dependency.recovered"
+)
+
+const (
+	webhookMaxAttempts = 5
+	webhookMaxBackoff  = time.Minute
+	// Number of delivery attempts kept for the delivery log
+	webhookDeliveryLogLength = 200
+)
+
+var webhookEvents = []string{
+	EventBotCreated,
+	EventBotJoined,
+	EventBotJoinFailed,
+	EventBotTaskChanged,
+	EventBotLanguageAdded,
+	EventBotLanguageRemoved,
+	EventBotStreamLost,
+	EventBotCaptionLost,
+	EventBotLanguageChanged,
+	EventBotRemoved,
+	EventDependencyDown,
+	EventDependencyRecovered,
+}
+
+// WebhookEvent is the JSON body of a webhook request.
+type WebhookEvent struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	Time      time.Time      `json:"time"`
+	BotID     string         `json:"bot_id,omitempty"`
+	MeetingID string         `json:"meeting_id,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// WebhookSpec is a subscription as created through the API.
+type WebhookSpec struct {
+	URL    string   `json:"url" format:"uri" doc:"URL the events are POSTed to, on a host in WEBHOOK_HOSTS"`
+	Secret string   `json:"secret,omitempty" doc:"HMAC secret, generated if empty"`
+	Events []string `json:"events,omitempty" doc:"Event types to send, all if empty"`
+}
+
+// WebhookSubscription is a stored subscription. The secret is only returned
+// when the subscription is created.
+type WebhookSubscription struct {
+	ID string `json:"id"`
+	WebhookSpec
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *WebhookSubscription) wants(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one attempt to deliver an event.
+type WebhookDelivery struct {
+	SubscriptionID string    `json:"subscription_id"`
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Attempt        int       `json:"attempt"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Success        bool      `json:"success"`
+	Time           time.Time `json:"time"`
+	DurationMs     float64   `json:"duration_ms"`
+}
+
+// Webhooks POSTs bot lifecycle and dependency events to all subscribers. Every request
+// carries the headers
+//
+//	X-Webhook-Event:     event type
+//	X-Webhook-Delivery:  event ID, the same for all retries
+//	X-Webhook-Timestamp: unix time the request was signed
+//	X-Webhook-Signature: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+//
+// Failed deliveries (network errors, 429 and 5xx) are retried with
+// exponential backoff. Subscriptions are stored in a JSON file.
+type Webhooks struct {
+	lock          sync.Mutex
+	file          string
+	hosts         []string
+	subscriptions map[string]*WebhookSubscription
+	deliveries    []WebhookDelivery
+	client        *http.Client
+}
+
+// NewWebhooks loads the subscriptions from file. An empty file name disables
+// persistence. Subscriptions can only be sent to the given hosts, otherwise
+// API clients could make the bot call internal servers and read the results
+// from the delivery log.
+func NewWebhooks(file string, hosts []string) (*Webhooks, error) {
+	w := &Webhooks{
+		file:          file,
+		hosts:         hosts,
+		subscriptions: make(map[string]*WebhookSubscription),
+		deliveries:    make([]WebhookDelivery, 0),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// A redirect could lead to a host which is not allowed
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	if file == "" {
+		return w, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read webhook file: %w", err)
+	}
+	subscriptions := make([]*WebhookSubscription, 0)
+	if err := json.Unmarshal(data, &subscriptions); err != nil {
+		return nil, fmt.Errorf("could not parse webhook file: %w", err)
+	}
+	for _, subscription := range subscriptions {
+		if err := w.checkURL(subscription.URL); err != nil {
+			log.Printf("[WARN] Webhook %s is not sent: %v", subscription.ID, err)
+		}
+		w.subscriptions[subscription.ID] = subscription
+	}
+	return w, nil
+}
+
+// checkURL returns an error if events can't be sent to the URL.
+func (w *Webhooks) checkURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("webhook URL must be an http(s) URL: %s", target)
+	}
+	if !hostAllowed(w.hosts, u) {
+		return fmt.Errorf("webhook host is not in WEBHOOK_HOSTS: %s", u.Hostname())
+	}
+	return nil
+}
+
+// hostAllowed reports whether the host of u is in hosts. An entry
+// "*.example.com" allows all subdomains of example.com.
+func hostAllowed(hosts []string, u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes all subscriptions to the file. The lock must be held.
+func (w *Webhooks) save() {
+	if w.file == "" {
+		return
+	}
+	subscriptions := make([]*WebhookSubscription, 0, len(w.subscriptions))
+	for _, subscription := range w.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	data, err := json.MarshalIndent(subscriptions, "", "  ")
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode webhooks: %v", err)
+		return
+	}
+	tmp := w.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("[ERROR] Failed to write webhook file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, w.file); err != nil {
+		log.Printf("[ERROR] Failed to write webhook file: %v", err)
+	}
+}
+
+func (w *Webhooks) Subscribe(spec WebhookSpec) (WebhookSubscription, error) {
+	if err := w.checkURL(spec.URL); err != nil {
+		return WebhookSubscription{}, err
+	}
+	for _, e := range spec.Events {
+		if !isWebhookEvent(e) {
+			return WebhookSubscription{}, fmt.Errorf("unknown event type: %s", e)
+		}
+	}
+	if spec.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return WebhookSubscription{}, err
+		}
+		spec.Secret = hex.EncodeToString(secret)
+	}
+
+	subscription := &WebhookSubscription{
+		ID:          uuid.New().String(),
+		WebhookSpec: spec,
+		CreatedAt:   time.Now(),
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.subscriptions[subscription.ID] = subscription
+	w.save()
+	return *subscription, nil
+}
+
+func (w *Webhooks) Unsubscribe(id string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if _, ok := w.subscriptions[id]; !ok {
+		return false
+	}
+	delete(w.subscriptions, id)
+	w.save()
+	return true
+}
+
+// Subscriptions returns all subscriptions without their secrets.
+func (w *Webhooks) Subscriptions() []WebhookSubscription {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	subscriptions := make([]WebhookSubscription, 0, len(w.subscriptions))
+	for _, subscription := range w.subscriptions {
+		s := *subscription
+		s.Secret = ""
+		subscriptions = append(subscriptions, s)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions
+}
+
+// Deliveries returns the latest delivery attempts, newest first. If
+// subscriptionID is set, only attempts for that subscription are returned.
+func (w *Webhooks) Deliveries(subscriptionID string) []WebhookDelivery {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	deliveries := make([]WebhookDelivery, 0, len(w.deliveries))
+	for i := len(w.deliveries) - 1; i >= 0; i-- {
+		if subscriptionID == "" || w.deliveries[i].SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, w.deliveries[i])
+		}
+	}
+	return deliveries
+}
+
+// Emit sends the event to all subscribers in the background. Emit on a nil
+// Webhooks does nothing.
+func (w *Webhooks) Emit(eventType string, b *Bot, data map[string]any) {
+	if w == nil {
+		return
+	}
+	w.emit(WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Time:      time.Now(),
+		BotID:     b.ID,
+		MeetingID: b.MeetingID,
+		Data:      data,
+	})
+}
+
+// EmitDependency sends a dependency event of the watchdog, which belongs to
+// no bot. EmitDependency on a nil Webhooks does nothing.
+func (w *Webhooks) EmitDependency(eventType string, status DependencyStatus) {
+	if w == nil {
+		return
+	}
+	data := map[string]any{"dependency": status.Name}
+	if status.Error != "" {
+		data["error"] = status.Error
+	}
+	w.emit(WebhookEvent{
+		ID:   uuid.New().String(),
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	})
+}
+
+func (w *Webhooks) emit(event WebhookEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode webhook event: %v", err)
+		return
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, subscription := range w.subscriptions {
+		if subscription.wants(event.Type) && w.checkURL(subscription.URL) == nil {
+			go w.deliver(*subscription, event, body)
+		}
+	}
+}
+
+func (w *Webhooks) deliver(subscription WebhookSubscription, event WebhookEvent, body []byte) {
+	backoff := time.Second
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		delivery := w.send(subscription, event, body)
+		delivery.Attempt = attempt
+		w.logDelivery(delivery)
+
+		if delivery.Success {
+			return
+		}
+		if delivery.StatusCode != 0 && delivery.StatusCode != http.StatusTooManyRequests &&
+			delivery.StatusCode < http.StatusInternalServerError {
+			log.Printf("[WARN] Webhook %s rejected event %s with status %d", subscription.ID, event.ID, delivery.StatusCode)
+			return
+		}
+
+		if attempt < webhookMaxAttempts {
+			time.Sleep(backoff)
+			backoff = min(backoff*2, webhookMaxBackoff)
+		}
+	}
+	log.Printf("[ERROR] Giving up on webhook %s for event %s after %d attempts", subscription.ID, event.ID, webhookMaxAttempts)
+}
+
+func (w *Webhooks) send(subscription WebhookSubscription, event WebhookEvent, body []byte) WebhookDelivery {
+	delivery := WebhookDelivery{
+		SubscriptionID: subscription.ID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		Time:           time.Now(),
+	}
+	defer func() {
+		delivery.DurationMs = float64(time.Since(delivery.Time).Microseconds()) / 1000
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", event.Type)
+	req.Header.Set("X-Webhook-Delivery", event.ID)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(subscription.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	return delivery
+}
+
+func (w *Webhooks) logDelivery(delivery WebhookDelivery) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.deliveries = append(w.deliveries, delivery)
+	if len(w.deliveries) > webhookDeliveryLogLength {
+		w.deliveries = w.deliveries[len(w.deliveries)-webhookDeliveryLogLength:]
+	}
+}
+
+// signWebhook returns the hex encoded HMAC-SHA256 of timestamp + "." + body.
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func isWebhookEvent(eventType string) bool {
+	for _, e := range webhookEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestWebhooksSubscribeChecksHost(t *testing.T) {
+	w, err := NewWebhooks("", []string{"hooks.example.com", "*.example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://hooks.example.com/events", true},
+		{"http://ci.example.org:8080/events", true},
+		{"ftp://hooks.example.com/events", false},
+		{"http://127.0.0.1:8080/events", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://localhost/events", false},
+		{"https://hooks.example.com.evil.net/events", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			_, err := w.Subscribe(WebhookSpec{URL: tt.url})
+			if got := err == nil; got != tt.want {
+				t.Errorf("subscribed %v, want %v (err: %v)", got, tt.want, err)
+			}
+		})
+	}
+}
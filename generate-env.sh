@@ -79,6 +79,64 @@ TRANSCRIPTION_TRANSCRIBE_CONFIRM_WORDS_CONFIRM_IF_OLDER_THEN="1.0"
 TRANSCRIPTION_TRANSLATE_CONFIRM_WORDS_CONFIRM_IF_OLDER_THEN="5.0"
 
 TRANSLATION_SERVER_URL="http://translation-service:5000/translate"
+
+AUDIO_SILENCE_SUPPRESSION="false"
+AUDIO_SILENCE_MAX_PAYLOAD="10"
+AUDIO_SILENCE_HANGOVER_MS="1000"
+TRANSCRIPTION_SERVER_TLS="false"
+TRANSCRIPTION_SERVER_TLS_CA=""
+TRANSCRIPTION_SERVER_TLS_SERVER_NAME=""
+TRANSCRIPTION_SERVER_TLS_CERT=""
+TRANSCRIPTION_SERVER_TLS_KEY=""
+TRANSCRIPTION_SERVER_KEY_PINS=""
+TRANSCRIPTION_SERVER_KNOWN_HOSTS=""
+# Optional list of transcription servers: host:tcp_port[:health_check_port],...
+TRANSCRIPTION_SERVERS=""
+WATCHDOG_INTERVAL_SECONDS="10"
+RATE_LIMIT_PER_MINUTE="0"
+# Per operation ID, e.g. bot-join=5,bot-translate-start=30
+RATE_LIMIT_ROUTES=""
+# Comma separated X-API-Key values which get their own limits and quotas,
+# requests with other keys are counted per IP address
+RATE_LIMIT_API_KEYS=""
+QUOTA_MAX_BOTS_PER_CLIENT="0"
+QUOTA_MAX_LANGUAGES_PER_CLIENT="0"
+SCHEDULE_FILE="schedules.json"
+WEBHOOK_FILE="webhooks.json"
+# Comma separated hosts lifecycle webhooks may be sent to, "*.example.com"
+# allows all subdomains. Empty allows no webhooks.
+WEBHOOK_HOSTS=""
+# Public URL of /api/v1/bbb/webhook, registered with the BBB webhooks module
+BBB_WEBHOOK_CALLBACK_URL=""
+BBB_WEBHOOK_JOIN_PATTERN=""
+BBB_WEBHOOK_JOIN_TASK="transcribe"
+BBB_WEBHOOK_JOIN_LANGUAGES=""
+# Lifecycle policy of new bots, 0 disables a rule
+BOT_IDLE_TIMEOUT_MINUTES="0"
+BOT_LEAVE_WHEN_ALONE="false"
+BOT_ALONE_GRACE_MINUTES="5"
+BOT_MAX_DURATION_MINUTES="0"
+CHAT_COMMANDS_ENABLED="false"
+CHAT_COMMAND_PREFIX="!"
+# Roles per command, e.g. captions=MODERATOR,stop=MODERATOR,mode=MODERATOR,status=*,help=*
+CHAT_COMMAND_PERMISSIONS=""
+# Language spoken in meetings, can be changed per bot
+BOT_SOURCE_LANGUAGE="en"
+# Caption pads are trimmed to PAD_KEEP_CHARS when they exceed PAD_MAX_CHARS (0 disables),
+# the trimmed text is kept in the bot history (GET /api/v1/bot/{bot_id}/history)
+PAD_MAX_CHARS=5000
+PAD_KEEP_CHARS=1000
+# Captions are also published as WebVTT subtitle playlists for HLS players
+# (GET /api/v1/bot/{bot_id}/hls), segments of HLS_SEGMENT_SECONDS, HLS_WINDOW_SEGMENTS per playlist
+HLS_SEGMENT_SECONDS=6
+HLS_WINDOW_SEGMENTS=10
+# Directory of the caption files bots write to (sink type "file", POST /api/v1/bot/{bot_id}/sinks)
+CAPTION_SINK_DIR="captions"
+# Comma separated hosts webhook and WebSocket sinks may send to, "*.example.com"
+# allows all subdomains. Empty allows no webhook or WebSocket sinks.
+CAPTION_SINK_HOSTS=""
+# Max sinks per bot, 0 means unlimited
+CAPTION_SINK_MAX_PER_BOT=5
 EOF
 )
 
@@ -135,6 +193,64 @@ TRANSCRIPTION_TRANSCRIBE_CONFIRM_WORDS_CONFIRM_IF_OLDER_THEN="1.0"
 TRANSCRIPTION_TRANSLATE_CONFIRM_WORDS_CONFIRM_IF_OLDER_THEN="5.0"
 
 TRANSLATION_SERVER_URL="http://localhost:8000/translate"
+
+AUDIO_SILENCE_SUPPRESSION="false"
+AUDIO_SILENCE_MAX_PAYLOAD="10"
+AUDIO_SILENCE_HANGOVER_MS="1000"
+TRANSCRIPTION_SERVER_TLS="false"
+TRANSCRIPTION_SERVER_TLS_CA=""
+TRANSCRIPTION_SERVER_TLS_SERVER_NAME=""
+TRANSCRIPTION_SERVER_TLS_CERT=""
+TRANSCRIPTION_SERVER_TLS_KEY=""
+TRANSCRIPTION_SERVER_KEY_PINS=""
+TRANSCRIPTION_SERVER_KNOWN_HOSTS=""
+# Optional list of transcription servers: host:tcp_port[:health_check_port],...
+TRANSCRIPTION_SERVERS=""
+WATCHDOG_INTERVAL_SECONDS="10"
+RATE_LIMIT_PER_MINUTE="0"
+# Per operation ID, e.g. bot-join=5,bot-translate-start=30
+RATE_LIMIT_ROUTES=""
+# Comma separated X-API-Key values which get their own limits and quotas,
+# requests with other keys are counted per IP address
+RATE_LIMIT_API_KEYS=""
+QUOTA_MAX_BOTS_PER_CLIENT="0"
+QUOTA_MAX_LANGUAGES_PER_CLIENT="0"
+SCHEDULE_FILE="schedules.json"
+WEBHOOK_FILE="webhooks.json"
+# Comma separated hosts lifecycle webhooks may be sent to, "*.example.com"
+# allows all subdomains. Empty allows no webhooks.
+WEBHOOK_HOSTS=""
+# Public URL of /api/v1/bbb/webhook, registered with the BBB webhooks module
+BBB_WEBHOOK_CALLBACK_URL=""
+BBB_WEBHOOK_JOIN_PATTERN=""
+BBB_WEBHOOK_JOIN_TASK="transcribe"
+BBB_WEBHOOK_JOIN_LANGUAGES=""
+# Lifecycle policy of new bots, 0 disables a rule
+BOT_IDLE_TIMEOUT_MINUTES="0"
+BOT_LEAVE_WHEN_ALONE="false"
+BOT_ALONE_GRACE_MINUTES="5"
+BOT_MAX_DURATION_MINUTES="0"
+CHAT_COMMANDS_ENABLED="false"
+CHAT_COMMAND_PREFIX="!"
+# Roles per command, e.g. captions=MODERATOR,stop=MODERATOR,mode=MODERATOR,status=*,help=*
+CHAT_COMMAND_PERMISSIONS=""
+# Language spoken in meetings, can be changed per bot
+BOT_SOURCE_LANGUAGE="en"
+# Caption pads are trimmed to PAD_KEEP_CHARS when they exceed PAD_MAX_CHARS (0 disables),
+# the trimmed text is kept in the bot history (GET /api/v1/bot/{bot_id}/history)
+PAD_MAX_CHARS=5000
+PAD_KEEP_CHARS=1000
+# Captions are also published as WebVTT subtitle playlists for HLS players
+# (GET /api/v1/bot/{bot_id}/hls), segments of HLS_SEGMENT_SECONDS, HLS_WINDOW_SEGMENTS per playlist
+HLS_SEGMENT_SECONDS=6
+HLS_WINDOW_SEGMENTS=10
+# Directory of the caption files bots write to (sink type "file", POST /api/v1/bot/{bot_id}/sinks)
+CAPTION_SINK_DIR="captions"
+# Comma separated hosts webhook and WebSocket sinks may send to, "*.example.com"
+# allows all subdomains. Empty allows no webhook or WebSocket sinks.
+CAPTION_SINK_HOSTS=""
+# Max sinks per bot, 0 means unlimited
+CAPTION_SINK_MAX_PER_BOT=5
 EOF
 )
 
@@ -191,6 +307,64 @@ TRANSCRIPTION_TRANSCRIBE_CONFIRM_WORDS_CONFIRM_IF_OLDER_THEN="1.0"
 TRANSCRIPTION_TRANSLATE_CONFIRM_WORDS_CONFIRM_IF_OLDER_THEN="5.0"
 
 TRANSLATION_SERVER_URL="http://translation-service:5000/translate"
+
+AUDIO_SILENCE_SUPPRESSION="false"
+AUDIO_SILENCE_MAX_PAYLOAD="10"
+AUDIO_SILENCE_HANGOVER_MS="1000"
+TRANSCRIPTION_SERVER_TLS="false"
+TRANSCRIPTION_SERVER_TLS_CA=""
+TRANSCRIPTION_SERVER_TLS_SERVER_NAME=""
+TRANSCRIPTION_SERVER_TLS_CERT=""
+TRANSCRIPTION_SERVER_TLS_KEY=""
+TRANSCRIPTION_SERVER_KEY_PINS=""
+TRANSCRIPTION_SERVER_KNOWN_HOSTS=""
+# Optional list of transcription servers: host:tcp_port[:health_check_port],...
+TRANSCRIPTION_SERVERS=""
+WATCHDOG_INTERVAL_SECONDS="10"
+RATE_LIMIT_PER_MINUTE="0"
+# Per operation ID, e.g. bot-join=5,bot-translate-start=30
+RATE_LIMIT_ROUTES=""
+# Comma separated X-API-Key values which get their own limits and quotas,
+# requests with other keys are counted per IP address
+RATE_LIMIT_API_KEYS=""
+QUOTA_MAX_BOTS_PER_CLIENT="0"
+QUOTA_MAX_LANGUAGES_PER_CLIENT="0"
+SCHEDULE_FILE="schedules.json"
+WEBHOOK_FILE="webhooks.json"
+# Comma separated hosts lifecycle webhooks may be sent to, "*.example.com"
+# allows all subdomains. Empty allows no webhooks.
+WEBHOOK_HOSTS=""
+# Public URL of /api/v1/bbb/webhook, registered with the BBB webhooks module
+BBB_WEBHOOK_CALLBACK_URL=""
+BBB_WEBHOOK_JOIN_PATTERN=""
+BBB_WEBHOOK_JOIN_TASK="transcribe"
+BBB_WEBHOOK_JOIN_LANGUAGES=""
+# Lifecycle policy of new bots, 0 disables a rule
+BOT_IDLE_TIMEOUT_MINUTES="0"
+BOT_LEAVE_WHEN_ALONE="false"
+BOT_ALONE_GRACE_MINUTES="5"
+BOT_MAX_DURATION_MINUTES="0"
+CHAT_COMMANDS_ENABLED="false"
+CHAT_COMMAND_PREFIX="!"
+# Roles per command, e.g. captions=MODERATOR,stop=MODERATOR,mode=MODERATOR,status=*,help=*
+CHAT_COMMAND_PERMISSIONS=""
+# Language spoken in meetings, can be changed per bot
+BOT_SOURCE_LANGUAGE="en"
+# Caption pads are trimmed to PAD_KEEP_CHARS when they exceed PAD_MAX_CHARS (0 disables),
+# the trimmed text is kept in the bot history (GET /api/v1/bot/{bot_id}/history)
+PAD_MAX_CHARS=5000
+PAD_KEEP_CHARS=1000
+# Captions are also published as WebVTT subtitle playlists for HLS players
+# (GET /api/v1/bot/{bot_id}/hls), segments of HLS_SEGMENT_SECONDS, HLS_WINDOW_SEGMENTS per playlist
+HLS_SEGMENT_SECONDS=6
+HLS_WINDOW_SEGMENTS=10
+# Directory of the caption files bots write to (sink type "file", POST /api/v1/bot/{bot_id}/sinks)
+CAPTION_SINK_DIR="captions"
+# Comma separated hosts webhook and WebSocket sinks may send to, "*.example.com"
+# allows all subdomains. Empty allows no webhook or WebSocket sinks.
+CAPTION_SINK_HOSTS=""
+# Max sinks per bot, 0 means unlimited
+CAPTION_SINK_MAX_PER_BOT=5
 EOF
 )
 